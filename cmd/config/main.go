@@ -3,6 +3,9 @@ package config
 import (
 	"flag"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type ContextKey string
@@ -12,17 +15,132 @@ var FlagOutputURL string
 var FlagLogLevel string
 var StoragePath string
 var DatabaseDsn string
+var DatabaseReplicaDsn string
+var FlagReplicaReadWindow time.Duration
+var FlagDatabaseMaxConns int
+var FlagDatabaseMinConns int
+var FlagDatabaseConnLifetime time.Duration
+var FlagDatabaseConnIdleTime time.Duration
+var FlagDatabaseStatementTimeout time.Duration
+var FlagMigrationTimeout time.Duration
+var FlagDatabaseRetries int
+var FlagDatabaseRetryBackoff time.Duration
+var FlagStorageURL string
+var FlagStorageMirrorURL string
+var FlagStorageMirrorQueue int
+var FlagStorageMirrorRetry time.Duration
+var FlagStorageCheckInterval time.Duration
+var FlagAccessLogLevel string
+var FlagAccessLogSampleFirst int
+var FlagAccessLogSampleThereafter int
+var FlagTraceExporter string
+var FlagTraceFile string
+var FlagRateLimitCreate float64
+var FlagRateLimitCreateBurst int
+var FlagRateLimitRedirect float64
+var FlagRateLimitRedirectBurst int
+var FlagRateLimitStore string
+var FlagTrustedProxies string
+var FlagAllowedSchemes string
+var FlagResolveHosts bool
+var FlagBlocklistPath string
+var FlagBlocklistReload time.Duration
+var FlagCanonicalStripTracking bool
+var FlagCanonicalTrackingParams string
+var FlagCanonicalStripTrailingSlash bool
+var FlagCanonicalSortQuery bool
+var FlagQRCacheSize int
+var FlagRedirectMode string
+var FlagRedirectStatus int
+var FlagPermanentRedirectMaxAge time.Duration
+var FlagPassthroughQuery string
+var FlagPasswordAttempts int
+var FlagPasswordAttemptsWindow time.Duration
+var FlagAdminToken string
+var FlagURLCacheSize int
+var FlagURLCacheTTL time.Duration
+var FlagURLCacheNegativeTTL time.Duration
+var FlagRedisURL string
+var FlagHealthTimeout time.Duration
+var FlagShutdownTimeout time.Duration
+
+// SQLiteScheme схема DSN базы данных, выбирающая хранилище SQLite: sqlite:///путь/к/файлу.db
+const SQLiteScheme = "sqlite://"
+
+// BoltScheme схема DSN базы данных, выбирающая встроенное хранилище bbolt: bolt:///путь/к/файлу.db
+const BoltScheme = "bolt://"
 
 const UserIDKey ContextKey = "userID"
 
+// UserGeneratedKey отмечает запрос, для которого ID пользователя только что создан
+const UserGeneratedKey ContextKey = "userGenerated"
+const RequestIDKey ContextKey = "requestID"
+
 func ParseFlags() {
-	flag.StringVar(&FlagRunAddr, "a", "localhost:8080", "Initial webserver URL")
-	flag.StringVar(&FlagOutputURL, "b", "http://localhost:8080", "Output short url host")
-	flag.StringVar(&FlagLogLevel, "l", "info", "log level")
-	flag.StringVar(&StoragePath, "file-storage-path", "/tmp/V23vlAC", "File urls storage path")
-	flag.StringVar(&DatabaseDsn, "d", "", "Database url")
+	RegisterFlags(flag.CommandLine)
 	flag.Parse()
+	ParseEnv()
+}
 
+// RegisterFlags добавляет флаги конфигурации в набор fs, чтобы их принимали и подкоманды
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&FlagRunAddr, "a", "localhost:8080", "Initial webserver URL")
+	fs.StringVar(&FlagOutputURL, "b", "http://localhost:8080", "Output short url host")
+	fs.StringVar(&FlagLogLevel, "l", "info", "log level")
+	fs.StringVar(&StoragePath, "file-storage-path", "/tmp/V23vlAC", "File urls storage path")
+	fs.StringVar(&DatabaseDsn, "d", "", "Database url: postgres://..., sqlite:///path/to/file.db or bolt:///path/to/file.db")
+	fs.StringVar(&DatabaseReplicaDsn, "database-replica-dsn", "", "Postgres replica DSN for reading links, empty reads from the main database")
+	fs.DurationVar(&FlagReplicaReadWindow, "replica-read-window", 5*time.Second, "How long links changed by a user are read from the main database instead of the replica")
+	fs.IntVar(&FlagDatabaseMaxConns, "database-max-conns", 0, "Postgres pool size, 0 keeps pool_max_conns from the DSN or the driver default")
+	fs.IntVar(&FlagDatabaseMinConns, "database-min-conns", 0, "Postgres connections kept open when idle")
+	fs.DurationVar(&FlagDatabaseConnLifetime, "database-conn-lifetime", time.Hour, "Postgres connection lifetime before it is reopened")
+	fs.DurationVar(&FlagDatabaseConnIdleTime, "database-conn-idle-time", 30*time.Minute, "Postgres idle connection lifetime")
+	fs.DurationVar(&FlagDatabaseStatementTimeout, "database-statement-timeout", 0, "Postgres statement timeout, 0 disables it")
+	fs.DurationVar(&FlagMigrationTimeout, "migration-timeout", 0, "Time limit for schema migrations at startup, 0 disables it")
+	fs.IntVar(&FlagDatabaseRetries, "database-retries", 3, "Retries of a Postgres operation after serialization failures and lost connections, 0 disables them")
+	fs.DurationVar(&FlagDatabaseRetryBackoff, "database-retry-backoff", 50*time.Millisecond, "Pause before the first Postgres retry, doubled for each next one")
+	fs.StringVar(&FlagStorageURL, "storage-url", "", "Storage URL: memory://, file:///path, postgres://..., sqlite:///path or bolt:///path; overrides -d and -file-storage-path")
+	fs.StringVar(&FlagStorageMirrorURL, "storage-mirror-url", "", "Storage URL that repeats every change of the main storage and serves reads while it is down, empty disables mirroring")
+	fs.IntVar(&FlagStorageMirrorQueue, "storage-mirror-queue", 10000, "Changes waiting to be written to the mirror, 0 means no limit")
+	fs.DurationVar(&FlagStorageMirrorRetry, "storage-mirror-retry", time.Second, "Pause before retrying a failed mirror write")
+	fs.DurationVar(&FlagStorageCheckInterval, "storage-check-interval", time.Second, "Main storage and replica health check interval when a mirror or replica is set")
+	fs.StringVar(&FlagAccessLogLevel, "access-log-level", "info", "Access log entries level, entries below the -l level are not written")
+	fs.IntVar(&FlagAccessLogSampleFirst, "access-log-sample-first", 100, "Access log entries written per second before sampling")
+	fs.IntVar(&FlagAccessLogSampleThereafter, "access-log-sample-thereafter", 0, "Write every Nth access log entry after the first ones, 0 disables sampling")
+	fs.StringVar(&FlagTraceExporter, "trace-exporter", "", "Trace exporter: otlp, stdout, file or empty to disable")
+	fs.StringVar(&FlagTraceFile, "trace-file", "/tmp/shortener-traces.json", "Trace file for the file exporter")
+	fs.Float64Var(&FlagRateLimitCreate, "rate-create", 10, "Link creation requests per second per user and IP, 0 disables the limit")
+	fs.IntVar(&FlagRateLimitCreateBurst, "rate-create-burst", 50, "Link creation burst size")
+	fs.Float64Var(&FlagRateLimitRedirect, "rate-redirect", 100, "Redirect requests per second per user and IP, 0 disables the limit")
+	fs.IntVar(&FlagRateLimitRedirectBurst, "rate-redirect-burst", 200, "Redirect burst size")
+	fs.StringVar(&FlagRateLimitStore, "rate-limit-store", "memory", "Rate limit counters storage: memory or postgres")
+	fs.StringVar(&FlagTrustedProxies, "trusted-proxies", "", "Comma separated proxy CIDRs allowed to set X-Forwarded-For and X-Real-IP")
+	fs.StringVar(&FlagAllowedSchemes, "allowed-schemes", "http,https", "Comma separated destination URL schemes")
+	fs.BoolVar(&FlagResolveHosts, "resolve-hosts", false, "Resolve destination hosts to reject private network addresses")
+	fs.StringVar(&FlagBlocklistPath, "blocklist", "", "Destination domain blocklist file")
+	fs.DurationVar(&FlagBlocklistReload, "blocklist-reload", 30*time.Second, "Blocklist file check interval")
+	fs.BoolVar(&FlagCanonicalStripTracking, "canonical-strip-tracking", false, "Remove tracking query params before saving URLs")
+	fs.StringVar(&FlagCanonicalTrackingParams, "canonical-tracking-params", "", "Comma separated tracking params, * suffix matches a prefix; empty uses utm_* and common click IDs")
+	fs.BoolVar(&FlagCanonicalStripTrailingSlash, "canonical-strip-trailing-slash", false, "Remove trailing slash from URL paths before saving")
+	fs.BoolVar(&FlagCanonicalSortQuery, "canonical-sort-query", true, "Sort query params by name before saving URLs")
+	fs.IntVar(&FlagQRCacheSize, "qr-cache-size", 1024, "Number of QR code images kept in memory")
+	fs.StringVar(&FlagRedirectMode, "redirect-mode", "direct", "Default redirect mode for links: direct or interstitial")
+	fs.IntVar(&FlagRedirectStatus, "redirect-status", 307, "Default redirect status code: 301, 302, 307 or 308")
+	fs.DurationVar(&FlagPermanentRedirectMaxAge, "permanent-redirect-max-age", 24*time.Hour, "Cache lifetime of permanent redirects")
+	fs.StringVar(&FlagPassthroughQuery, "passthrough-query", "override", "Conflicting query params on passthrough: override, keep or append")
+	fs.IntVar(&FlagPasswordAttempts, "password-attempts", 5, "Wrong password attempts allowed per link within the window, 0 disables the limit")
+	fs.DurationVar(&FlagPasswordAttemptsWindow, "password-attempts-window", 15*time.Minute, "Window in which wrong password attempts are counted")
+	fs.StringVar(&FlagAdminToken, "admin-token", "", "Token for admin endpoints, empty disables them")
+	fs.IntVar(&FlagURLCacheSize, "url-cache-size", 0, "Number of links kept in the in-process cache, 0 disables it; with several instances edits reach other instances' caches only after url-cache-ttl")
+	fs.DurationVar(&FlagURLCacheTTL, "url-cache-ttl", time.Minute, "Lifetime of cached links, 0 disables caching")
+	fs.DurationVar(&FlagURLCacheNegativeTTL, "url-cache-negative-ttl", 10*time.Second, "Lifetime of cached unknown codes, 0 disables negative caching")
+	fs.StringVar(&FlagRedisURL, "redis-url", "", "Redis URL for the shared link cache, empty disables it")
+	fs.DurationVar(&FlagHealthTimeout, "health-timeout", time.Second, "Timeout of each component check in /ping, /healthz and /readyz")
+	fs.DurationVar(&FlagShutdownTimeout, "shutdown-timeout", 10*time.Second, "Time to finish in-flight requests after SIGINT or SIGTERM")
+}
+
+// ParseEnv переопределяет значения флагов переменными окружения
+func ParseEnv() {
 	if envRunAddr := os.Getenv("SERVER_ADDRESS"); envRunAddr != "" {
 		FlagRunAddr = envRunAddr
 	}
@@ -39,4 +157,205 @@ func ParseFlags() {
 		DatabaseDsn = envDatabaseDsn
 	}
 
+	if envReplicaDsn := os.Getenv("DATABASE_REPLICA_DSN"); envReplicaDsn != "" {
+		DatabaseReplicaDsn = envReplicaDsn
+	}
+
+	if envReplicaWindow, err := time.ParseDuration(os.Getenv("REPLICA_READ_WINDOW")); err == nil {
+		FlagReplicaReadWindow = envReplicaWindow
+	}
+
+	if envMaxConns, err := strconv.Atoi(os.Getenv("DATABASE_MAX_CONNS")); err == nil {
+		FlagDatabaseMaxConns = envMaxConns
+	}
+
+	if envMinConns, err := strconv.Atoi(os.Getenv("DATABASE_MIN_CONNS")); err == nil {
+		FlagDatabaseMinConns = envMinConns
+	}
+
+	if envConnLifetime, err := time.ParseDuration(os.Getenv("DATABASE_CONN_LIFETIME")); err == nil {
+		FlagDatabaseConnLifetime = envConnLifetime
+	}
+
+	if envConnIdleTime, err := time.ParseDuration(os.Getenv("DATABASE_CONN_IDLE_TIME")); err == nil {
+		FlagDatabaseConnIdleTime = envConnIdleTime
+	}
+
+	if envStatementTimeout, err := time.ParseDuration(os.Getenv("DATABASE_STATEMENT_TIMEOUT")); err == nil {
+		FlagDatabaseStatementTimeout = envStatementTimeout
+	}
+
+	if envMigrationTimeout, err := time.ParseDuration(os.Getenv("MIGRATION_TIMEOUT")); err == nil {
+		FlagMigrationTimeout = envMigrationTimeout
+	}
+
+	if envRetries, err := strconv.Atoi(os.Getenv("DATABASE_RETRIES")); err == nil {
+		FlagDatabaseRetries = envRetries
+	}
+
+	if envRetryBackoff, err := time.ParseDuration(os.Getenv("DATABASE_RETRY_BACKOFF")); err == nil {
+		FlagDatabaseRetryBackoff = envRetryBackoff
+	}
+
+	if envStorageURL := os.Getenv("STORAGE_URL"); envStorageURL != "" {
+		FlagStorageURL = envStorageURL
+	}
+
+	if envStorageMirrorURL := os.Getenv("STORAGE_MIRROR_URL"); envStorageMirrorURL != "" {
+		FlagStorageMirrorURL = envStorageMirrorURL
+	}
+
+	if envMirrorQueue, err := strconv.Atoi(os.Getenv("STORAGE_MIRROR_QUEUE")); err == nil {
+		FlagStorageMirrorQueue = envMirrorQueue
+	}
+
+	if envMirrorRetry, err := time.ParseDuration(os.Getenv("STORAGE_MIRROR_RETRY")); err == nil {
+		FlagStorageMirrorRetry = envMirrorRetry
+	}
+
+	if envCheckInterval, err := time.ParseDuration(os.Getenv("STORAGE_CHECK_INTERVAL")); err == nil {
+		FlagStorageCheckInterval = envCheckInterval
+	}
+
+	if envAccessLogLevel := os.Getenv("ACCESS_LOG_LEVEL"); envAccessLogLevel != "" {
+		FlagAccessLogLevel = envAccessLogLevel
+	}
+
+	if envSampleFirst, err := strconv.Atoi(os.Getenv("ACCESS_LOG_SAMPLE_FIRST")); err == nil {
+		FlagAccessLogSampleFirst = envSampleFirst
+	}
+
+	if envSampleThereafter, err := strconv.Atoi(os.Getenv("ACCESS_LOG_SAMPLE_THEREAFTER")); err == nil {
+		FlagAccessLogSampleThereafter = envSampleThereafter
+	}
+
+	if envTraceExporter := os.Getenv("TRACE_EXPORTER"); envTraceExporter != "" {
+		FlagTraceExporter = envTraceExporter
+	}
+
+	if envTraceFile := os.Getenv("TRACE_FILE"); envTraceFile != "" {
+		FlagTraceFile = envTraceFile
+	}
+
+	if envRateCreate, err := strconv.ParseFloat(os.Getenv("RATE_LIMIT_CREATE"), 64); err == nil {
+		FlagRateLimitCreate = envRateCreate
+	}
+
+	if envRateCreateBurst, err := strconv.Atoi(os.Getenv("RATE_LIMIT_CREATE_BURST")); err == nil {
+		FlagRateLimitCreateBurst = envRateCreateBurst
+	}
+
+	if envRateRedirect, err := strconv.ParseFloat(os.Getenv("RATE_LIMIT_REDIRECT"), 64); err == nil {
+		FlagRateLimitRedirect = envRateRedirect
+	}
+
+	if envRateRedirectBurst, err := strconv.Atoi(os.Getenv("RATE_LIMIT_REDIRECT_BURST")); err == nil {
+		FlagRateLimitRedirectBurst = envRateRedirectBurst
+	}
+
+	if envRateLimitStore := os.Getenv("RATE_LIMIT_STORE"); envRateLimitStore != "" {
+		FlagRateLimitStore = envRateLimitStore
+	}
+
+	if envTrustedProxies := os.Getenv("TRUSTED_PROXIES"); envTrustedProxies != "" {
+		FlagTrustedProxies = envTrustedProxies
+	}
+
+	if envAllowedSchemes := os.Getenv("ALLOWED_SCHEMES"); envAllowedSchemes != "" {
+		FlagAllowedSchemes = envAllowedSchemes
+	}
+
+	if envResolveHosts, err := strconv.ParseBool(os.Getenv("RESOLVE_HOSTS")); err == nil {
+		FlagResolveHosts = envResolveHosts
+	}
+
+	if envBlocklistPath := os.Getenv("BLOCKLIST_PATH"); envBlocklistPath != "" {
+		FlagBlocklistPath = envBlocklistPath
+	}
+
+	if envBlocklistReload, err := time.ParseDuration(os.Getenv("BLOCKLIST_RELOAD")); err == nil {
+		FlagBlocklistReload = envBlocklistReload
+	}
+
+	if envStripTracking, err := strconv.ParseBool(os.Getenv("CANONICAL_STRIP_TRACKING")); err == nil {
+		FlagCanonicalStripTracking = envStripTracking
+	}
+
+	if envTrackingParams := os.Getenv("CANONICAL_TRACKING_PARAMS"); envTrackingParams != "" {
+		FlagCanonicalTrackingParams = envTrackingParams
+	}
+
+	if envStripTrailingSlash, err := strconv.ParseBool(os.Getenv("CANONICAL_STRIP_TRAILING_SLASH")); err == nil {
+		FlagCanonicalStripTrailingSlash = envStripTrailingSlash
+	}
+
+	if envSortQuery, err := strconv.ParseBool(os.Getenv("CANONICAL_SORT_QUERY")); err == nil {
+		FlagCanonicalSortQuery = envSortQuery
+	}
+
+	if envQRCacheSize, err := strconv.Atoi(os.Getenv("QR_CACHE_SIZE")); err == nil {
+		FlagQRCacheSize = envQRCacheSize
+	}
+
+	if envRedirectMode := os.Getenv("REDIRECT_MODE"); envRedirectMode != "" {
+		FlagRedirectMode = envRedirectMode
+	}
+
+	if envRedirectStatus, err := strconv.Atoi(os.Getenv("REDIRECT_STATUS")); err == nil {
+		FlagRedirectStatus = envRedirectStatus
+	}
+
+	if envMaxAge, err := time.ParseDuration(os.Getenv("PERMANENT_REDIRECT_MAX_AGE")); err == nil {
+		FlagPermanentRedirectMaxAge = envMaxAge
+	}
+
+	if envPassthroughQuery := os.Getenv("PASSTHROUGH_QUERY"); envPassthroughQuery != "" {
+		FlagPassthroughQuery = envPassthroughQuery
+	}
+
+	if envPasswordAttempts, err := strconv.Atoi(os.Getenv("PASSWORD_ATTEMPTS")); err == nil {
+		FlagPasswordAttempts = envPasswordAttempts
+	}
+
+	if envPasswordWindow, err := time.ParseDuration(os.Getenv("PASSWORD_ATTEMPTS_WINDOW")); err == nil {
+		FlagPasswordAttemptsWindow = envPasswordWindow
+	}
+
+	if envAdminToken := os.Getenv("ADMIN_TOKEN"); envAdminToken != "" {
+		FlagAdminToken = envAdminToken
+	}
+
+	if envURLCacheSize, err := strconv.Atoi(os.Getenv("URL_CACHE_SIZE")); err == nil {
+		FlagURLCacheSize = envURLCacheSize
+	}
+
+	if envURLCacheTTL, err := time.ParseDuration(os.Getenv("URL_CACHE_TTL")); err == nil {
+		FlagURLCacheTTL = envURLCacheTTL
+	}
+
+	if envNegativeTTL, err := time.ParseDuration(os.Getenv("URL_CACHE_NEGATIVE_TTL")); err == nil {
+		FlagURLCacheNegativeTTL = envNegativeTTL
+	}
+
+	if envRedisURL := os.Getenv("REDIS_URL"); envRedisURL != "" {
+		FlagRedisURL = envRedisURL
+	}
+
+	if envHealthTimeout, err := time.ParseDuration(os.Getenv("HEALTH_TIMEOUT")); err == nil {
+		FlagHealthTimeout = envHealthTimeout
+	}
+
+	if envShutdownTimeout, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil {
+		FlagShutdownTimeout = envShutdownTimeout
+	}
+
+}
+
+// DSNPath возвращает путь к файлу БД, если dsn задан со схемой встроенной БД scheme
+func DSNPath(dsn, scheme string) (string, bool) {
+	if !strings.HasPrefix(dsn, scheme) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(dsn, scheme), true
 }
@@ -1,37 +1,82 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"encoding/base64"
-	json2 "encoding/json"
 	"errors"
 	"fmt"
 	"github.com/go-chi/chi"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/laiker/shortener/cmd/config"
 	logger "github.com/laiker/shortener/internal"
+	"github.com/laiker/shortener/internal/canonical"
 	compresser "github.com/laiker/shortener/internal/gzip"
+	"github.com/laiker/shortener/internal/health"
 	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/qr"
+	"github.com/laiker/shortener/internal/ratelimit"
 	"github.com/laiker/shortener/internal/store"
+	"github.com/laiker/shortener/internal/tracing"
+	"github.com/laiker/shortener/internal/urlcheck"
 	"github.com/mailru/easyjson"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // app инкапсулирует в себя все зависимости и логику приложения
 type app struct {
-	store store.Store
+	store         store.Store
+	checker       urlcheck.Checker
+	canonicalizer *canonical.Canonicalizer
+	qrCache       *qr.Cache
+	limiter       ratelimit.Limiter
+	passwordRule  ratelimit.Rule
+	liveness      *health.Checker
+	readiness     *health.Checker
 }
 
 // newApp принимает на вход внешние зависимости приложения и возвращает новый объект app
 func newApp(s store.Store) *app {
 	return &app{
-		store: s,
+		store:         s,
+		checker:       defaultChecks(),
+		canonicalizer: defaultCanonicalizer(),
+		qrCache:       qr.NewCache(config.FlagQRCacheSize),
+		limiter:       ratelimit.NewMemoryLimiter(),
+		passwordRule:  defaultPasswordRule(),
+		liveness:      livenessChecks(s),
+		readiness:     readinessChecks(s),
+	}
+}
+
+// defaultCanonicalizer настраивает приведение адресов к каноническому виду по конфигурации
+func defaultCanonicalizer() *canonical.Canonicalizer {
+	trackingParams := canonical.DefaultTrackingParams
+
+	if config.FlagCanonicalTrackingParams != "" {
+		trackingParams = strings.Split(config.FlagCanonicalTrackingParams, ",")
+	}
+
+	return canonical.New(canonical.Options{
+		StripTracking:      config.FlagCanonicalStripTracking,
+		TrackingParams:     trackingParams,
+		StripTrailingSlash: config.FlagCanonicalStripTrailingSlash,
+		SortQuery:          config.FlagCanonicalSortQuery,
+	})
+}
+
+// defaultChecks собирает проверки адресов назначения, которые не требуют внешних ресурсов
+func defaultChecks() urlcheck.Pipeline {
+	return urlcheck.Pipeline{
+		urlcheck.Schemes(strings.Split(config.FlagAllowedSchemes, ",")...),
+		urlcheck.PrivateNetworks(config.FlagResolveHosts),
+		urlcheck.SelfReference(config.FlagOutputURL),
 	}
 }
 
@@ -45,7 +90,7 @@ const SECRET_KEY = "supersecretkey"
 
 func (a *app) pingHandler(w http.ResponseWriter, r *http.Request) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), config.FlagHealthTimeout)
 	defer cancel()
 
 	if err := a.store.PingContext(ctx); err != nil {
@@ -60,6 +105,10 @@ func (a *app) pingHandler(w http.ResponseWriter, r *http.Request) {
 
 func (a *app) gzipMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Start(r.Context(), "gzipMiddleware")
+		defer span.End()
+		r = r.WithContext(ctx)
+
 		ow := w
 
 		acceptContent := r.Header.Get("Content-Type")
@@ -77,6 +126,11 @@ func (a *app) gzipMiddleware(h http.Handler) http.Handler {
 		acceptEncoding := r.Header.Get("Accept-Encoding")
 		supportsGzip := strings.Contains(acceptEncoding, "gzip")
 
+		span.SetAttributes(
+			attribute.Bool("gzip.response", supportsGzip && supportContent),
+			attribute.Bool("gzip.request", strings.Contains(r.Header.Get("Content-Encoding"), "gzip") && supportContent),
+		)
+
 		if supportsGzip && supportContent {
 			cw := compresser.NewCompressWriter(w)
 			ow = cw
@@ -159,11 +213,14 @@ func (a *app) userMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ow := w
 
+		_, span := tracing.Start(r.Context(), "userMiddleware.jwt")
+
 		authIdCookie, err := r.Cookie("Authorization")
 		authIdHeader := r.Header.Get("Authorization")
 
 		var userId string
 		var tokenString string
+		var generated bool
 
 		if authIdHeader != "" {
 			tokenString = authIdHeader
@@ -177,17 +234,23 @@ func (a *app) userMiddleware(h http.Handler) http.Handler {
 
 		if userId == "" {
 			userId = generateUniqueID()
-			logger.Log.Info("user ID generated: " + userId)
+			logger.FromContext(r.Context()).Info("user ID generated: " + userId)
 
 			tokenString, err = BuildJWTString(userId)
 			fmt.Println(tokenString)
 			if err != nil {
-				logger.Log.Info("Create new token failed: " + err.Error())
+				logger.FromContext(r.Context()).Info("Create new token failed: " + err.Error())
+				tracing.EndSpan(span, err)
 				http.Error(w, "Token creation failed", http.StatusInternalServerError)
 				return
 			}
+
+			generated = true
+			span.SetAttributes(attribute.Bool("user.generated", true))
 		}
 
+		span.End()
+
 		/*authCookie := http.Cookie{
 			Name:     "Authorization",
 			Value:    tokenString,
@@ -203,6 +266,7 @@ func (a *app) userMiddleware(h http.Handler) http.Handler {
 		w.Header().Set("Authorization", tokenString)
 
 		ctx := context.WithValue(r.Context(), config.UserIDKey, userId)
+		ctx = context.WithValue(ctx, config.UserGeneratedKey, generated)
 		r = r.WithContext(ctx)
 
 		h.ServeHTTP(ow, r)
@@ -210,8 +274,9 @@ func (a *app) userMiddleware(h http.Handler) http.Handler {
 }
 
 func (a *app) shortenHandler(w http.ResponseWriter, r *http.Request) {
-	logger.Log.Info("shortenHandler")
-	logger.Log.Info(r.Method)
+	log := logger.FromContext(r.Context())
+	log.Info("shortenHandler")
+	log.Info(r.Method)
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
@@ -237,25 +302,75 @@ func (a *app) shortenHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !validMode(urlType.Mode) || !validRedirectType(urlType.RedirectType) {
+		log.Info("Bad Request wrong link options")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	tags, err := normalizeTags(urlType.Tags)
+
+	if err != nil {
+		log.Info("Bad Request wrong tags")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	uri, err = a.prepareURL(r.Context(), uri)
+
+	if err != nil {
+		log.Info("Unsafe url", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
 	bodyURL := uri.String()
 
 	encodedURL := a.encodeURL(bodyURL)
-	finalURL := fmt.Sprintf("%s/%s", config.FlagOutputURL, encodedURL)
+
+	var passwordHash string
+
+	if urlType.Password != "" {
+		passwordHash, err = hashPassword(urlType.Password)
+
+		if err != nil {
+			log.Info("Bad Request wrong password", zap.Error(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		code, err := generateCode()
+
+		if err != nil {
+			log.Info("Code generation failed", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		encodedURL = []byte(code)
+	}
+
+	code, errsave := a.SaveURL(r.Context(), json.DBRow{
+		ShortURL:     string(encodedURL),
+		OriginalURL:  bodyURL,
+		Mode:         urlType.Mode,
+		RedirectType: urlType.RedirectType,
+		Passthrough:  urlType.Passthrough,
+		PasswordHash: passwordHash,
+		Tags:         tags,
+	})
 
 	result := &json.Result{}
-	result.Result = finalURL
+	result.Result = fmt.Sprintf("%s/%s", config.FlagOutputURL, code)
 	response, err := easyjson.Marshal(result)
 
-	errsave := a.SaveURL(r.Context(), string(encodedURL), bodyURL)
-
 	if errsave != nil && errors.Is(errsave, store.ErrUnique) {
-		fmt.Println("err")
 		w.WriteHeader(http.StatusConflict)
 		w.Write(response)
 		return
 	}
 
-	if err != nil {
+	if err != nil || errsave != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -265,7 +380,8 @@ func (a *app) shortenHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *app) shortenBatchHandler(w http.ResponseWriter, r *http.Request) {
-	logger.Log.Info("shortenBatchHandler")
+	log := logger.FromContext(r.Context())
+	log.Info("shortenBatchHandler")
 
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -276,8 +392,9 @@ func (a *app) shortenBatchHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	if err != nil {
-		logger.Log.Info("Bad Request body not read")
+		log.Info("Bad Request body not read")
 		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 
 	var batchSlice json.BatchURLSlice
@@ -285,8 +402,9 @@ func (a *app) shortenBatchHandler(w http.ResponseWriter, r *http.Request) {
 	err = easyjson.Unmarshal(body, &batchSlice)
 
 	if err != nil {
-		logger.Log.Info("Bad Request can't unmarshal")
+		log.Info("Bad Request can't unmarshal")
 		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 
 	result := make(json.BatchURLSlice, 0)
@@ -298,48 +416,70 @@ func (a *app) shortenBatchHandler(w http.ResponseWriter, r *http.Request) {
 
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
-			logger.Log.Info("Error can't parse")
+			log.Info("Error can't parse")
 			return
 		}
 
-		bodyURL := uri.String()
+		uri, err = a.prepareURL(r.Context(), uri)
 
-		encodedURL := a.encodeURL(bodyURL)
-
-		err = a.SaveURL(r.Context(), string(encodedURL), bodyURL)
+		if err != nil {
+			log.Info("Unsafe url", zap.Error(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
 
-		if err != nil && errors.Is(err, store.ErrUnique) {
-			logger.Log.Info("dublicate url")
-			w.WriteHeader(http.StatusConflict)
-			w.Write(encodedURL)
+		if !validMode(currentItem.Mode) || !validRedirectType(currentItem.RedirectType) {
+			log.Info("Bad Request wrong link options")
+			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
+		tags, err := normalizeTags(currentItem.Tags)
+
 		if err != nil {
+			log.Info("Bad Request wrong tags")
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
-		batchSaveURL := json.DBRow{
-			ShortURL:    currentItem.ShortURL,
-			OriginalURL: currentItem.OriginalURL,
-		}
+		bodyURL := uri.String()
 
-		batchOutputURL := json.DBRow{
-			CorrelationID: currentItem.CorrelationID,
-			ShortURL:      fmt.Sprintf("%s/%s", config.FlagOutputURL, encodedURL),
+		encodedURL := a.encodeURL(bodyURL)
+
+		batchSaveURL := json.DBRow{
+			ShortURL:     string(encodedURL),
+			OriginalURL:  bodyURL,
+			Mode:         currentItem.Mode,
+			RedirectType: currentItem.RedirectType,
+			Passthrough:  currentItem.Passthrough,
+			Tags:         tags,
 		}
 
 		saveBatch = append(saveBatch, batchSaveURL)
-		result = append(result, batchOutputURL)
 	}
 
-	err = a.store.SaveBatchURL(context.Background(), saveBatch)
+	err = a.SaveBatchURL(r.Context(), saveBatch)
+
+	if err != nil && errors.Is(err, store.ErrUnique) {
+		log.Info("dublicate url")
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
 
 	if err != nil {
+		log.Info("Batch save failed", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	// коды берём после сохранения: занятые коды заменены случайными
+	for i, saved := range saveBatch {
+		result = append(result, json.DBRow{
+			CorrelationID: batchSlice[i].CorrelationID,
+			ShortURL:      fmt.Sprintf("%s/%s", config.FlagOutputURL, saved.ShortURL),
+		})
+	}
+
 	response, err := easyjson.Marshal(result)
 
 	if err != nil {
@@ -354,7 +494,8 @@ func (a *app) shortenBatchHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *app) encodeHandler(w http.ResponseWriter, r *http.Request) {
-	logger.Log.Info("encodeHandler")
+	log := logger.FromContext(r.Context())
+	log.Info("encodeHandler")
 
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -365,28 +506,39 @@ func (a *app) encodeHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	if err != nil {
-		logger.Log.Info("Bad Request can't read")
+		log.Info("Bad Request can't read")
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
 	bodyURL := string(reqURL)
 
-	_, err = url.ParseRequestURI(bodyURL)
+	uri, err := url.ParseRequestURI(bodyURL)
 
 	if err != nil {
-		logger.Log.Info("Bad Request can't parse")
+		log.Info("Bad Request can't parse")
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	response := a.encodeURL(bodyURL)
-	shortURL := fmt.Sprintf("%s/%s", config.FlagOutputURL, response)
+	uri, err = a.prepareURL(r.Context(), uri)
+
+	if err != nil {
+		log.Info("Unsafe url", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 
-	err = a.SaveURL(r.Context(), string(response), bodyURL)
+	bodyURL = uri.String()
+
+	code, err := a.SaveURL(r.Context(), json.DBRow{
+		ShortURL:    string(a.encodeURL(bodyURL)),
+		OriginalURL: bodyURL,
+	})
+
+	shortURL := fmt.Sprintf("%s/%s", config.FlagOutputURL, code)
 
 	if err != nil && errors.Is(err, store.ErrUnique) {
-		fmt.Println("test")
 		w.WriteHeader(http.StatusConflict)
 		w.Write([]byte(shortURL))
 		return
@@ -398,59 +550,234 @@ func (a *app) encodeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	logger.Log.Info(config.FlagOutputURL)
+	log.Info(config.FlagOutputURL)
 	w.Write([]byte(shortURL))
 
 }
 
 func (a *app) decodeHandler(w http.ResponseWriter, r *http.Request) {
 
-	logger.Log.Info("decodeHandler")
-	if r.Method != http.MethodGet {
+	log := logger.FromContext(r.Context())
+	log.Info("decodeHandler")
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
 	id := chi.URLParam(r, "id")
 
-	result, err := a.decodeURL(id)
+	row, stored, err := a.resolveURL(r.Context(), id)
+
+	// код с плюсом на конце открывает страницу предпросмотра ссылки
+	preview := false
+
+	if err != nil && strings.HasSuffix(id, "+") {
+		row, stored, err = a.resolveURL(r.Context(), strings.TrimSuffix(id, "+"))
+		preview = true
+	}
+
+	// POST принимает только форма пароля защищённой ссылки
+	if r.Method == http.MethodPost && (err != nil || row.PasswordHash == "") {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if stored && expired(row) {
+		http.Error(w, "Error: link expired", http.StatusGone)
+		return
+	}
+
+	result := row.OriginalURL
+
+	// код может быть собран вручную, поэтому адрес проверяем и при редиректе
+	uri, err := url.Parse(result)
+
+	if err == nil {
+		err = a.checker.Check(r.Context(), uri)
+	}
 
 	if err != nil {
+		log.Info("Unsafe redirect", zap.Error(err))
 		http.Error(w, "Error: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Location", result)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	if row.PasswordHash != "" && !a.checkPassword(w, r, row) {
+		return
+	}
+
+	if preview {
+		renderPage(w, previewPage, newPageData(row, stored))
+		return
+	}
+
+	// путь после кода переносим только для ссылок с passthrough, для остальных его нет
+	rctx := chi.RouteContext(r.Context())
+	onSuffixRoute := rctx != nil && rctx.RoutePattern() == "/{id}/*"
+
+	if onSuffixRoute && !row.Passthrough {
+		http.NotFound(w, r)
+		return
+	}
+
+	if row.Passthrough {
+		result, err = passthroughURL(result, pathSuffix(r), r.URL.RawQuery, config.FlagPassthroughQuery)
+
+		if err != nil {
+			http.Error(w, "Error: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		row.OriginalURL = result
+	}
+
+	if stored {
+		if err := a.store.IncrementClicks(r.Context(), row.ShortURL); err != nil {
+			log.Info("Clicks increment failed", zap.Error(err))
+		}
+	}
+
+	if linkMode(row) == modeInterstitial {
+		renderPage(w, interstitialPage, newPageData(row, stored))
+		return
+	}
+
+	status := redirectStatus(row)
+
+	if row.PasswordHash != "" {
+		status = passwordRedirectStatus(r, status)
+	}
+
+	writeRedirect(w, result, status)
+}
+
+func (a *app) qrHandler(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	log.Info("qrHandler")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if _, _, err := a.resolveURL(r.Context(), id); err != nil {
+		http.Error(w, "Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts := qr.DefaultOptions()
+	query := r.URL.Query()
+
+	if format := query.Get("format"); format != "" {
+		opts.Format = format
+	}
+
+	if level := query.Get("level"); level != "" {
+		opts.Level = level
+	}
+
+	var err error
+
+	if size := query.Get("size"); size != "" {
+		if opts.Size, err = strconv.Atoi(size); err != nil {
+			http.Error(w, "Error: wrong size", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if margin := query.Get("margin"); margin != "" {
+		if opts.Margin, err = strconv.Atoi(margin); err != nil {
+			http.Error(w, "Error: wrong margin", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := opts.Validate(); err != nil {
+		http.Error(w, "Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	image, err := a.qrCache.Encode(fmt.Sprintf("%s/%s", config.FlagOutputURL, id), opts)
+
+	if err != nil && errors.Is(err, qr.ErrInvalidOptions) {
+		http.Error(w, "Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		log.Info("QR code encoding failed", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", opts.ContentType())
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	w.Write(image)
 }
 
 func (a *app) userUrlsHandler(w http.ResponseWriter, r *http.Request) {
-	logger.Log.Info("userUrlsHandler")
+	log := logger.FromContext(r.Context())
+	log.Info("userUrlsHandler")
 
 	userId := r.Context().Value(config.UserIDKey).(string)
 
-	logger.Log.Info("get userId " + userId)
+	log.Info("get userId " + userId)
 
 	if userId == "" {
 		w.WriteHeader(http.StatusUnauthorized)
 	}
 
-	fmt.Println(userId)
-	urls, err := a.store.GetUserURLs(r.Context(), userId)
+	opts, err := listOptions(r.URL.Query(), userId)
+
+	if err != nil {
+		http.Error(w, "Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if err != nil || len(urls) == 0 {
+	pageSize := opts.Limit
+
+	// лишняя ссылка показывает, что за этой страницей есть следующая
+	if pageSize > 0 {
+		opts.Limit++
+	}
+
+	urls, err := a.store.ListUserURLs(r.Context(), opts)
+
+	if err != nil {
+		log.Info("List user urls failed", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if len(urls) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
+	if pageSize > 0 && len(urls) > pageSize {
+		urls = urls[:pageSize]
+		setNextLink(w, r, opts, urls[pageSize-1])
+	}
+
 	result := make(json.BatchURLSlice, 0)
 
 	for i := 0; i < len(urls); i++ {
 		currentItem := urls[i]
 
 		batchOutputURL := json.DBRow{
-			OriginalURL: currentItem.ShortURL,
-			ShortURL:    fmt.Sprintf("%s/%s", config.FlagOutputURL, currentItem.OriginalURL),
+			OriginalURL: currentItem.OriginalURL,
+			ShortURL:    fmt.Sprintf("%s/%s", config.FlagOutputURL, currentItem.ShortURL),
+			CreatedAt:   currentItem.CreatedAt,
+			Clicks:      currentItem.Clicks,
+			Tags:        currentItem.Tags,
 		}
 
 		result = append(result, batchOutputURL)
@@ -469,6 +796,43 @@ func (a *app) userUrlsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(response)
 }
 
+// prepareURL приводит адрес назначения к каноническому виду и проверяет его безопасность
+func (a *app) prepareURL(ctx context.Context, uri *url.URL) (*url.URL, error) {
+	uri, err := a.canonicalizer.Canonicalize(uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.checker.Check(ctx, uri); err != nil {
+		return nil, err
+	}
+
+	return uri, nil
+}
+
+// resolveURL ищет ссылку по коду в хранилище. Коды, которых там нет, раскодируются напрямую,
+// в этом случае stored равен false
+func (a *app) resolveURL(ctx context.Context, code string) (row json.DBRow, stored bool, err error) {
+	row, err = a.store.GetURL(ctx, code)
+
+	if err == nil {
+		return row, true, nil
+	}
+
+	if !errors.Is(err, store.ErrNotFound) {
+		logger.FromContext(ctx).Info("Get url failed", zap.Error(err))
+	}
+
+	original, err := a.decodeURL(code)
+
+	if err != nil {
+		return row, false, err
+	}
+
+	return json.DBRow{ShortURL: code, OriginalURL: original}, false, nil
+}
+
 func (a *app) decodeURL(code string) (string, error) {
 	data, err := base64.StdEncoding.DecodeString(code)
 
@@ -483,65 +847,82 @@ func (a *app) encodeURL(url string) []byte {
 	return []byte(base64.StdEncoding.EncodeToString([]byte(url)))
 }
 
-func (a *app) SaveURL(ctx context.Context, short, original string) error {
+// codeAttempts сколько раз сохранение повторяется со случайным кодом, если код занят
+const codeAttempts = 3
 
-	if config.DatabaseDsn != "" {
-		logger.Log.Info("Try to save url into Database: " + original)
-		ctxx, cancel := context.WithTimeout(ctx, 1*time.Second)
-		defer cancel()
-		err := a.store.SaveURL(ctxx, short, original)
+// SaveURL сохраняет ссылку и возвращает её код. Если код занят изменённой ссылкой,
+// ссылка сохраняется под случайным кодом. При ErrUnique возвращает код уже сохранённой ссылки
+func (a *app) SaveURL(ctx context.Context, row json.DBRow) (string, error) {
 
-		if err != nil {
-			return err
-		}
+	logger.FromContext(ctx).Info("Try to save url: " + row.OriginalURL)
+
+	// владелец нужен для редактирования ссылки, поэтому заполняем его явно
+	if row.UserID == "" {
+		row.UserID, _ = ctx.Value(config.UserIDKey).(string)
 	}
 
-	if config.StoragePath != "" {
+	ctxx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
 
-		file, err := os.OpenFile(config.StoragePath, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0775)
+	err := a.store.SaveURL(ctxx, row)
 
-		if err != nil {
-			return err
+	for attempt := 0; errors.Is(err, store.ErrCodeTaken) && attempt < codeAttempts; attempt++ {
+		if row.ShortURL, err = generateCode(); err != nil {
+			return "", err
 		}
 
-		encoder := json2.NewEncoder(file)
+		err = a.store.SaveURL(ctxx, row)
+	}
 
-		reader := bufio.NewReader(file)
+	// код сохранённой ссылки может отличаться от base64 адреса
+	var unique *store.UniqueError
 
-		var lastLine string
+	if errors.As(err, &unique) {
+		return unique.ShortURL, err
+	}
 
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				break // Достигнут конец файла
-			}
-			lastLine = line
-			if strings.TrimSpace(line) == "}" {
-				break
-			}
-		}
+	return row.ShortURL, err
+}
 
-		lastRow := &json.DBRow{}
-		lastID := 1
-		if lastLine != "" {
-			if err := json2.Unmarshal([]byte(lastLine), &lastRow); err != nil {
-				return err
-			}
+// SaveBatchURL сохраняет пачку ссылок. Занятые коды заменяются в rows случайными
+func (a *app) SaveBatchURL(ctx context.Context, rows json.BatchURLSlice) error {
+	userID, _ := ctx.Value(config.UserIDKey).(string)
 
-			lastID = lastRow.ID + 1
+	for i := range rows {
+		if rows[i].UserID == "" {
+			rows[i].UserID = userID
 		}
+	}
+
+	err := a.store.SaveBatchURL(ctx, rows)
 
-		row := &json.DBRow{
-			ID:          lastID,
-			OriginalURL: original,
-			ShortURL:    short,
+	for attempt := 0; errors.Is(err, store.ErrCodeTaken) && attempt < codeAttempts; attempt++ {
+		if err = a.replaceTakenCodes(ctx, rows); err != nil {
+			return err
 		}
 
-		err = encoder.Encode(row)
+		err = a.store.SaveBatchURL(ctx, rows)
+	}
+
+	return err
+}
+
+// replaceTakenCodes заменяет случайными коды ссылок rows, которые уже есть в хранилище
+func (a *app) replaceTakenCodes(ctx context.Context, rows json.BatchURLSlice) error {
+	for i := range rows {
+		_, err := a.store.GetURL(ctx, rows[i].ShortURL)
+
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
 
 		if err != nil {
 			return err
 		}
+
+		if rows[i].ShortURL, err = generateCode(); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi"
+	"github.com/laiker/shortener/cmd/config"
+	logger "github.com/laiker/shortener/internal"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"github.com/mailru/easyjson"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// expired сообщает, что срок действия ссылки истёк
+func expired(row json.DBRow) bool {
+	return row.ExpiresAt != nil && !time.Now().Before(*row.ExpiresAt)
+}
+
+// ownedURL возвращает ссылку code, если она принадлежит пользователю из контекста.
+// Чужие ссылки не отличаются от несуществующих, чтобы не раскрывать занятые коды.
+func (a *app) ownedURL(ctx context.Context, code string) (json.DBRow, error) {
+	userID, _ := ctx.Value(config.UserIDKey).(string)
+
+	row, err := a.store.GetURL(ctx, code)
+
+	if err != nil {
+		return row, err
+	}
+
+	if userID == "" || row.UserID != userID {
+		return json.DBRow{}, store.ErrNotFound
+	}
+
+	return row, nil
+}
+
+// applyPatch переносит в row заполненные поля patch
+func (a *app) applyPatch(ctx context.Context, row json.DBRow, patch json.URLPatch) (json.DBRow, error) {
+	if patch.URL != nil {
+		uri, err := url.ParseRequestURI(*patch.URL)
+
+		if err != nil {
+			return row, err
+		}
+
+		uri, err = a.prepareURL(ctx, uri)
+
+		if err != nil {
+			return row, err
+		}
+
+		row.OriginalURL = uri.String()
+	}
+
+	if patch.RedirectType != nil {
+		if !validRedirectType(*patch.RedirectType) {
+			return row, fmt.Errorf("unsupported redirect type %d", *patch.RedirectType)
+		}
+
+		row.RedirectType = *patch.RedirectType
+	}
+
+	if patch.ExpiresAt != nil {
+		row.ExpiresAt = nil
+
+		if *patch.ExpiresAt != "" {
+			expiresAt, err := time.Parse(time.RFC3339, *patch.ExpiresAt)
+
+			if err != nil {
+				return row, err
+			}
+
+			row.ExpiresAt = &expiresAt
+		}
+	}
+
+	if patch.Tags != nil {
+		tags, err := normalizeTags(*patch.Tags)
+
+		if err != nil {
+			return row, err
+		}
+
+		row.Tags = tags
+	}
+
+	return row, nil
+}
+
+// writeURL отвечает ссылкой row без служебных полей
+func writeURL(w http.ResponseWriter, row json.DBRow) {
+	response, err := easyjson.Marshal(json.DBRow{
+		ShortURL:     fmt.Sprintf("%s/%s", config.FlagOutputURL, row.ShortURL),
+		OriginalURL:  row.OriginalURL,
+		CreatedAt:    row.CreatedAt,
+		RedirectType: row.RedirectType,
+		ExpiresAt:    row.ExpiresAt,
+		UpdatedAt:    row.UpdatedAt,
+		Tags:         row.Tags,
+	})
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// updateURL сохраняет изменённую ссылку и отвечает её новой версией
+func (a *app) updateURL(w http.ResponseWriter, r *http.Request, row json.DBRow) {
+	err := a.store.UpdateURL(r.Context(), row)
+
+	if errors.Is(err, store.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		logger.FromContext(r.Context()).Info("Update url failed", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	row, err = a.store.GetURL(r.Context(), row.ShortURL)
+
+	if err != nil {
+		logger.FromContext(r.Context()).Info("Get url failed", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeURL(w, row)
+}
+
+func (a *app) updateURLHandler(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	log.Info("updateURLHandler")
+
+	if r.Method != http.MethodPatch {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	patch := json.URLPatch{}
+
+	if err := easyjson.Unmarshal(body, &patch); err != nil {
+		log.Info("Bad Request can't unmarshal")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	row, err := a.ownedURL(r.Context(), chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	row, err = a.applyPatch(r.Context(), row, patch)
+
+	if err != nil {
+		log.Info("Bad Request wrong patch", zap.Error(err))
+		http.Error(w, "Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.updateURL(w, r, row)
+}
+
+func (a *app) urlHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	log.Info("urlHistoryHandler")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	row, err := a.ownedURL(r.Context(), chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	history, err := a.store.GetURLHistory(r.Context(), row.ShortURL)
+
+	if err != nil {
+		log.Info("Get url history failed", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response, err := easyjson.Marshal(json.URLHistorySlice(history))
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// rollbackHandler возвращает ссылке одну из прежних версий. Текущая версия при этом
+// попадает в историю, так что откат тоже можно отменить.
+func (a *app) rollbackHandler(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	log.Info("rollbackHandler")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	rollback := json.Rollback{}
+
+	if err := easyjson.Unmarshal(body, &rollback); err != nil {
+		log.Info("Bad Request can't unmarshal")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	row, err := a.ownedURL(r.Context(), chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	history, err := a.store.GetURLHistory(r.Context(), row.ShortURL)
+
+	if err != nil {
+		log.Info("Get url history failed", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	for _, version := range history {
+		if version.Version != rollback.Version {
+			continue
+		}
+
+		row.OriginalURL = version.OriginalURL
+		row.RedirectType = version.RedirectType
+		row.ExpiresAt = version.ExpiresAt
+		row.Tags = version.Tags
+
+		a.updateURL(w, r, row)
+		return
+	}
+
+	http.Error(w, "Error: unknown version", http.StatusNotFound)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/laiker/shortener/cmd/config"
+	logger "github.com/laiker/shortener/internal"
+	"github.com/laiker/shortener/internal/exporter"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+)
+
+// exportCommand выполняет подкоманду export: выгружает ссылки из хранилища целиком,
+// со служебными полями. Выгрузку в JSON lines можно открыть как файл file.Store.
+func exportCommand(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", exporter.FormatJSONL, "Output format: csv or jsonl")
+	output := fs.String("output", "-", "Output file, stdout when -")
+	userID := fs.String("user", "", "Export only links of this user")
+	config.RegisterFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	config.ParseEnv()
+
+	if err := logger.Initialize(config.FlagLogLevel); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cstore, _, closeStore, err := openStore(ctx)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	defer closeStore()
+
+	var dst io.Writer = os.Stdout
+
+	if *output != "-" {
+		file, err := os.Create(*output)
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		defer file.Close()
+		dst = file
+	}
+
+	count, err := exporter.Export(ctx, cstore, dst, exporter.Options{Format: *format, UserID: *userID})
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d\n", count)
+
+	return 0
+}
+
+// exportURL убирает из ссылки служебные поля перед выгрузкой пользователю
+func exportURL(row json.DBRow) json.DBRow {
+	return json.DBRow{
+		ShortURL:     fmt.Sprintf("%s/%s", config.FlagOutputURL, row.ShortURL),
+		OriginalURL:  row.OriginalURL,
+		Mode:         row.Mode,
+		RedirectType: row.RedirectType,
+		Passthrough:  row.Passthrough,
+		CreatedAt:    row.CreatedAt,
+		UpdatedAt:    row.UpdatedAt,
+		ExpiresAt:    row.ExpiresAt,
+		Clicks:       row.Clicks,
+		Tags:         row.Tags,
+	}
+}
+
+// exportHandler потоково выгружает все ссылки пользователя в CSV или JSON lines
+func (a *app) exportHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(config.UserIDKey).(string)
+
+	if userID == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	a.export(w, r, exporter.Options{UserID: userID, Transform: exportURL})
+}
+
+// adminExportHandler выгружает ссылки всех пользователей или пользователя из параметра user
+// со служебными полями
+func (a *app) adminExportHandler(w http.ResponseWriter, r *http.Request) {
+	a.export(w, r, exporter.Options{UserID: r.URL.Query().Get("user")})
+}
+
+func (a *app) export(w http.ResponseWriter, r *http.Request, opts exporter.Options) {
+	log := logger.FromContext(r.Context())
+	log.Info("exportHandler")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	opts.Format = r.URL.Query().Get("format")
+
+	if opts.Format == "" {
+		opts.Format = exporter.FormatJSONL
+	}
+
+	if opts.Format != exporter.FormatCSV && opts.Format != exporter.FormatJSONL {
+		http.Error(w, "Error: "+exporter.ErrUnknownFormat.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", exporter.ContentType(opts.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="links.%s"`, opts.Format))
+	w.WriteHeader(http.StatusOK)
+
+	// заголовки уже отправлены, поэтому об ошибке посреди выгрузки можно только записать в лог
+	count, err := exporter.Export(r.Context(), a.store, w, opts)
+
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Info("Export failed", zap.Error(err), zap.Int("exported", count))
+	}
+}
+
+// adminBackupHandler отдаёт резервную копию БД, не останавливая работу сервера. Доступен,
+// если хранилище умеет делать копии, сейчас это только bbolt.
+func (a *app) adminBackupHandler(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	log.Info("adminBackupHandler")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	backuper, ok := store.BackuperOf(a.store)
+
+	if !ok {
+		http.Error(w, "Error: backup is not supported by store", http.StatusNotImplemented)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="backup.db"`)
+	w.WriteHeader(http.StatusOK)
+
+	size, err := backuper.Backup(r.Context(), w)
+
+	if err != nil {
+		log.Info("Backup failed", zap.Error(err), zap.Int64("written", size))
+	}
+}
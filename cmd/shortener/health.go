@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	json2 "encoding/json"
+	"errors"
+	"fmt"
+	"github.com/laiker/shortener/cmd/config"
+	logger "github.com/laiker/shortener/internal"
+	"github.com/laiker/shortener/internal/health"
+	"github.com/laiker/shortener/internal/store"
+	"github.com/laiker/shortener/internal/store/cache"
+	"github.com/laiker/shortener/internal/store/file"
+	"github.com/laiker/shortener/internal/store/mirror"
+	"github.com/laiker/shortener/internal/store/pg"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// livenessChecks проверяет сам процесс: фоновые обработчики хранилища работают. Внешние
+// сервисы здесь не проверяются, чтобы их отказ не приводил к перезапуску сервиса
+func livenessChecks(s store.Store) *health.Checker {
+	checker := &health.Checker{}
+	addWorkerChecks(checker, s)
+
+	return checker
+}
+
+// readinessChecks проверяет, что сервис может обслуживать запросы: хранилище отвечает, его
+// схема обновлена, файл хранилища доступен на запись, кэш и фоновые обработчики работают.
+// С зеркалом сервис готов, пока отвечает хранилище, из которого читаются ссылки, а отказ
+// основного хранилища только ухудшает его состояние
+func readinessChecks(s store.Store) *health.Checker {
+	checker := &health.Checker{}
+
+	ping := s.PingContext
+	// primaryCritical без основного хранилища сервис не готов
+	primaryCritical := true
+
+	if mirrorStore, ok := store.Find[*mirror.Store](s); ok {
+		ping = mirrorStore.PingReader
+		primaryCritical = false
+
+		checker.Add("primary", false, func(ctx context.Context) (map[string]any, error) {
+			return nil, s.PingContext(ctx)
+		})
+	}
+
+	checker.Add("store", true, func(ctx context.Context) (map[string]any, error) {
+		return nil, ping(ctx)
+	})
+
+	if versioned, ok := store.Find[store.Versioned](s); ok {
+		checker.Add("migrations", primaryCritical, func(ctx context.Context) (map[string]any, error) {
+			version, latest, err := versioned.SchemaVersion(ctx)
+
+			if err != nil {
+				return nil, err
+			}
+
+			details := map[string]any{"version": version, "latest": latest}
+
+			if version < latest {
+				return details, fmt.Errorf("schema version %d is behind %d", version, latest)
+			}
+
+			return details, nil
+		})
+	}
+
+	if fileStore, ok := store.Find[*file.Store](s); ok {
+		checker.Add("file", primaryCritical, func(ctx context.Context) (map[string]any, error) {
+			return nil, fileStore.Writable()
+		})
+	}
+
+	if cacheStore, ok := store.Find[*cache.Store](s); ok {
+		checker.Add("cache", false, func(ctx context.Context) (map[string]any, error) {
+			return nil, cacheStore.Ping(ctx)
+		})
+	}
+
+	addWorkerChecks(checker, s)
+
+	return checker
+}
+
+// addWorkerChecks добавляет проверки фоновых обработчиков: очереди зеркала и проверки реплики
+func addWorkerChecks(checker *health.Checker, s store.Store) {
+	if mirrorStore, ok := store.Find[*mirror.Store](s); ok {
+		checker.Add("mirror", true, func(ctx context.Context) (map[string]any, error) {
+			details := map[string]any{
+				"pending":      mirrorStore.Pending(),
+				"dropped":      mirrorStore.Dropped(),
+				"primary_down": mirrorStore.PrimaryDown(),
+			}
+
+			if !mirrorStore.Running() {
+				return details, errors.New("mirror worker is not running")
+			}
+
+			return details, nil
+		})
+	}
+
+	if pgStore, ok := store.Find[*pg.Store](s); ok && pgStore.HasReplica() {
+		checker.Add("replica", true, func(ctx context.Context) (map[string]any, error) {
+			// недоступная реплика не отказ: ссылки читаются с основной БД
+			details := map[string]any{"replica_down": pgStore.ReplicaDown()}
+
+			if !pgStore.ReplicaRunning() {
+				return details, errors.New("replica worker is not running")
+			}
+
+			return details, nil
+		})
+	}
+}
+
+// healthzHandler отвечает на пробу liveness
+func (a *app) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	a.writeHealth(w, r, a.liveness)
+}
+
+// readyzHandler отвечает на пробу readiness
+func (a *app) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	a.writeHealth(w, r, a.readiness)
+}
+
+// writeHealth выполняет проверки checker и отвечает 503, если отказал критичный компонент
+func (a *app) writeHealth(w http.ResponseWriter, r *http.Request, checker *health.Checker) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	report := checker.Run(r.Context(), config.FlagHealthTimeout)
+
+	if report.Status != health.StatusOK {
+		logger.FromContext(r.Context()).Info("Health check failed", zap.String("path", r.URL.Path), zap.Any("report", report))
+	}
+
+	status := http.StatusOK
+
+	if report.Status == health.StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json2.NewEncoder(w).Encode(report)
+}
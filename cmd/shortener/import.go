@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/laiker/shortener/cmd/config"
+	logger "github.com/laiker/shortener/internal"
+	"github.com/laiker/shortener/internal/exporter"
+	"github.com/laiker/shortener/internal/importer"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/mailru/easyjson"
+	"go.uber.org/zap"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+)
+
+// adminTokenHeader заголовок с токеном администратора
+const adminTokenHeader = "X-Admin-Token"
+
+// maxReportRejects ограничивает число отклонённых строк в ответе на импорт через API
+const maxReportRejects = 1000
+
+// importCommand выполняет подкоманду import: загружает в хранилище ссылки из файлов
+// CSV или JSON lines. Принимает те же флаги конфигурации, что и сервер.
+func importCommand(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "Input format: csv or jsonl, detected by file extension when empty")
+	batchSize := fs.Int("batch", importer.DefaultBatchSize, "Links saved per batch")
+	owner := fs.String("owner", "import", "Owner of links without one")
+	reportPath := fs.String("report", "", "File for rejected rows in JSON lines, stderr when empty")
+	config.RegisterFlags(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: shortener import [flags] file.csv|file.jsonl|- ...")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	config.ParseEnv()
+
+	if err := logger.Initialize(config.FlagLogLevel); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	files := fs.Args()
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cstore, _, closeStore, err := openStore(ctx)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	defer closeStore()
+
+	var report io.Writer = os.Stderr
+
+	if *reportPath != "" {
+		reportFile, err := os.Create(*reportPath)
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		defer reportFile.Close()
+		report = reportFile
+	}
+
+	rejects := bufio.NewWriter(report)
+	defer rejects.Flush()
+
+	a := newApp(cstore)
+
+	for _, name := range files {
+		opts := a.importOptions(*format, *owner, *batchSize)
+		opts.Reject = func(rejection json.Rejection) {
+			line, _ := easyjson.Marshal(rejection)
+			rejects.Write(append(line, '\n'))
+		}
+
+		if opts.Format == "" {
+			opts.Format = importer.FormatOf(name)
+		}
+
+		result, err := importFile(ctx, a, name, opts)
+
+		fmt.Printf("%s: read %d, imported %d, rejected %d\n", name, result.Read, result.Imported, result.Rejected)
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func importFile(ctx context.Context, a *app, name string, opts importer.Options) (json.ImportReport, error) {
+	var src io.Reader = os.Stdin
+
+	if name != "-" {
+		file, err := os.Open(name)
+
+		if err != nil {
+			return json.ImportReport{}, err
+		}
+
+		defer file.Close()
+		src = file
+	}
+
+	return importer.Import(ctx, bufio.NewReaderSize(src, 64*1024), a.store, opts)
+}
+
+// importOptions настраивает импорт на те же проверки и коды, что и при создании ссылок
+func (a *app) importOptions(format, owner string, batchSize int) importer.Options {
+	return importer.Options{
+		Format:    format,
+		BatchSize: batchSize,
+		Owner:     owner,
+		Prepare:   a.prepareURL,
+		Encode: func(destination string) string {
+			return string(a.encodeURL(destination))
+		},
+		Decode:   a.decodeURL,
+		Reserved: reservedCodes,
+	}
+}
+
+// adminMiddleware пропускает только запросы с токеном администратора из конфигурации.
+// Без настроенного токена административные маршруты недоступны.
+func (a *app) adminMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if config.FlagAdminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		token := r.Header.Get(adminTokenHeader)
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(config.FlagAdminToken)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// importHandler загружает ссылки из тела запроса. Формат задаётся параметром format
+// или заголовком Content-Type, в ответе отчёт с первыми отклонёнными строками.
+func (a *app) importHandler(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	log.Info("importHandler")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	defer r.Body.Close()
+
+	query := r.URL.Query()
+	format := query.Get("format")
+
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		switch mediaType {
+		case "text/csv":
+			format = exporter.FormatCSV
+		case "application/x-ndjson", "application/jsonl":
+			format = exporter.FormatJSONL
+		}
+	}
+
+	batchSize := importer.DefaultBatchSize
+
+	if batch := query.Get("batch"); batch != "" {
+		var err error
+
+		if batchSize, err = strconv.Atoi(batch); err != nil || batchSize <= 0 {
+			http.Error(w, "Error: wrong batch", http.StatusBadRequest)
+			return
+		}
+	}
+
+	owner := query.Get("owner")
+
+	if owner == "" {
+		owner = "import"
+	}
+
+	var rejects []json.Rejection
+
+	opts := a.importOptions(format, owner, batchSize)
+	opts.Reject = func(rejection json.Rejection) {
+		if len(rejects) < maxReportRejects {
+			rejects = append(rejects, rejection)
+		}
+	}
+
+	report, err := importer.Import(r.Context(), r.Body, a.store, opts)
+	report.Rejects = rejects
+
+	if errors.Is(err, importer.ErrUnknownFormat) {
+		http.Error(w, "Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// при сбое хранилища часть ссылок уже сохранена, поэтому отчёт нужен и в этом случае
+	status := http.StatusOK
+
+	if err != nil {
+		log.Info("Import failed", zap.Error(err), zap.Int("imported", report.Imported))
+		status = http.StatusInternalServerError
+	}
+
+	response, err := easyjson.Marshal(report)
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(response)
+}
@@ -2,30 +2,47 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/go-chi/chi"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/laiker/shortener/cmd/config"
 	logger "github.com/laiker/shortener/internal"
+	"github.com/laiker/shortener/internal/ratelimit"
 	"github.com/laiker/shortener/internal/store"
-	"github.com/laiker/shortener/internal/store/file"
-	"github.com/laiker/shortener/internal/store/memory"
-	"github.com/laiker/shortener/internal/store/pg"
+	"github.com/laiker/shortener/internal/store/cache"
+	"github.com/laiker/shortener/internal/tracing"
+	"github.com/laiker/shortener/internal/urlcheck"
 	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			os.Exit(importCommand(os.Args[2:]))
+		case "export":
+			os.Exit(exportCommand(os.Args[2:]))
+		case "migrate-store":
+			os.Exit(migrateCommand(os.Args[2:]))
+		}
+	}
+
 	config.ParseFlags()
 	run()
 }
 
-func run() {
-	var db *pgxpool.Pool
-	var cstore store.Store
+// reservedCodes первые сегменты путей, которые заняты маршрутами сервиса, и суффикс QR-кода.
+// Статические маршруты chi выигрывают у /{id}, поэтому ссылка с таким кодом была бы недоступна
+var reservedCodes = []string{"api", "ping", "healthz", "readyz", "qr"}
 
+func run() {
 	r := chi.NewRouter()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
@@ -36,49 +53,189 @@ func run() {
 		fmt.Println(err)
 	}
 
-	cstore = memory.NewStore()
-	logger.Log.Info("Store Memory")
+	err = logger.InitializeAccessLog(config.FlagAccessLogLevel, config.FlagAccessLogSampleFirst, config.FlagAccessLogSampleThereafter)
+	if err != nil {
+		fmt.Println(err)
+	}
 
-	if config.StoragePath != "" {
-		cstore = file.NewStore(config.StoragePath)
-		logger.Log.Info("Store File")
+	shutdownTracing, err := tracing.Initialize(context.Background(), "shortener", config.FlagTraceExporter, config.FlagTraceFile)
+	if err != nil {
+		logger.Log.Info(err.Error())
+		return
+	}
+
+	defer func() {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		shutdownTracing(shutdownCtx)
+	}()
 
+	cstore, db, closeStore, err := openStore(ctx)
+
+	if err != nil {
+		logger.Log.Info(err.Error())
+		return
+	}
+
+	defer closeStore()
+
+	cstore, err = cacheStore(cstore)
+
+	if err != nil {
+		logger.Log.Info(err.Error())
+		return
+	}
+
+	if config.FlagRedirectMode == "" || !validMode(config.FlagRedirectMode) {
+		logger.Log.Info("Unknown redirect mode " + config.FlagRedirectMode)
+		return
+	}
+
+	if config.FlagRedirectStatus == 0 || !validRedirectType(config.FlagRedirectStatus) {
+		logger.Log.Info(fmt.Sprintf("Unsupported redirect status %d", config.FlagRedirectStatus))
+		return
+	}
+
+	if !validQueryRule(config.FlagPassthroughQuery) {
+		logger.Log.Info("Unknown passthrough query rule " + config.FlagPassthroughQuery)
+		return
 	}
 
-	if config.DatabaseDsn != "" {
-		logger.Log.Info("DSN " + config.DatabaseDsn)
-		logger.Log.Info("Store postgres")
+	appInstance := newApp(cstore)
 
-		db, err = pgxpool.New(context.Background(), config.DatabaseDsn)
+	if config.FlagBlocklistPath != "" {
+		blocklist, err := urlcheck.NewBlocklist(config.FlagBlocklistPath)
 
 		if err != nil {
 			logger.Log.Info(err.Error())
 			return
 		}
 
-		cstore = pg.NewStore(db)
+		go blocklist.Watch(context.Background(), config.FlagBlocklistReload)
 
-		defer db.Close()
+		appInstance.checker = append(defaultChecks(), blocklist)
 	}
 
-	err = cstore.Bootstrap(ctx)
+	trustedProxies, err := ratelimit.ParseTrustedProxies(config.FlagTrustedProxies)
 
 	if err != nil {
 		logger.Log.Info(err.Error())
 		return
 	}
 
-	appInstance := newApp(cstore)
+	var limiter ratelimit.Limiter = ratelimit.NewMemoryLimiter()
+
+	if config.FlagRateLimitStore == "postgres" {
+		if db == nil {
+			logger.Log.Info("Rate limit store postgres requires DSN, using memory")
+		} else {
+			pgLimiter := ratelimit.NewPgLimiter(db)
 
-	r.Use(logger.RequestLogger, appInstance.gzipMiddleware)
-	r.Use(logger.RequestLogger, appInstance.userMiddleware)
-	r.HandleFunc("/api/shorten/batch", appInstance.shortenBatchHandler)
+			if err := pgLimiter.Bootstrap(ctx); err != nil {
+				logger.Log.Info(err.Error())
+				return
+			}
+
+			limiter = pgLimiter
+		}
+	}
+
+	appInstance.limiter = limiter
+
+	limitKeys := ratelimit.UserAndIPKeys(trustedProxies)
+	createLimit := ratelimit.Middleware(limiter, ratelimit.Rule{
+		Name:  "create",
+		Rate:  config.FlagRateLimitCreate,
+		Burst: config.FlagRateLimitCreateBurst,
+	}, limitKeys)
+	redirectLimit := ratelimit.Middleware(limiter, ratelimit.Rule{
+		Name:  "redirect",
+		Rate:  config.FlagRateLimitRedirect,
+		Burst: config.FlagRateLimitRedirectBurst,
+	}, limitKeys)
+
+	// первые сегменты статических маршрутов перечислены в reservedCodes
+	r.Use(tracing.Middleware, logger.RequestLogger, appInstance.gzipMiddleware, appInstance.userMiddleware)
+	r.With(createLimit).HandleFunc("/api/shorten/batch", appInstance.shortenBatchHandler)
 	r.HandleFunc("/api/user/urls", appInstance.userUrlsHandler)
-	r.HandleFunc("/api/shorten", appInstance.shortenHandler)
-	r.HandleFunc("/{id}", appInstance.decodeHandler)
+	r.HandleFunc("/api/user/urls/export", appInstance.exportHandler)
+	r.With(createLimit).HandleFunc("/api/user/urls/{id}", appInstance.updateURLHandler)
+	r.HandleFunc("/api/user/urls/{id}/history", appInstance.urlHistoryHandler)
+	r.With(createLimit).HandleFunc("/api/user/urls/{id}/rollback", appInstance.rollbackHandler)
+	r.HandleFunc("/api/user/tags", appInstance.tagsHandler)
+	r.With(createLimit).HandleFunc("/api/user/tags/{tag}", appInstance.tagHandler)
+	r.With(createLimit).HandleFunc("/api/shorten", appInstance.shortenHandler)
+	r.With(redirectLimit).HandleFunc("/{id}", appInstance.decodeHandler)
+	// путь qr зарезервирован за QR-кодом ссылки и не переносится в адрес назначения даже у ссылок с passthrough
+	r.With(redirectLimit).HandleFunc("/{id}/qr", appInstance.qrHandler)
+	r.With(redirectLimit).HandleFunc("/{id}/*", appInstance.decodeHandler)
+	r.With(appInstance.adminMiddleware).HandleFunc("/api/admin/import", appInstance.importHandler)
+	r.With(appInstance.adminMiddleware).HandleFunc("/api/admin/export", appInstance.adminExportHandler)
+	r.With(appInstance.adminMiddleware).HandleFunc("/api/admin/backup", appInstance.adminBackupHandler)
 	r.HandleFunc("/ping", appInstance.pingHandler)
-	r.HandleFunc("/", appInstance.encodeHandler)
+	r.HandleFunc("/healthz", appInstance.healthzHandler)
+	r.HandleFunc("/readyz", appInstance.readyzHandler)
+	r.With(createLimit).HandleFunc("/", appInstance.encodeHandler)
+
+	server := &http.Server{Addr: config.FlagRunAddr, Handler: r}
+
+	// по сигналу сервер дожидается текущих запросов, после чего отложенные вызовы
+	// закрывают хранилище и дописывают очередь зеркала
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// shutdown закрывается, когда сервер закончил текущие запросы
+	shutdown := make(chan struct{})
+
+	go func() {
+		defer close(shutdown)
+
+		<-signalCtx.Done()
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), config.FlagShutdownTimeout)
+		defer shutdownCancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Log.Info("Server shutdown failed", zap.Error(err))
+		}
+	}()
 
 	logger.Log.Info("Server runs at: ", zap.String("address", config.FlagRunAddr))
-	http.ListenAndServe(config.FlagRunAddr, r)
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Log.Info(err.Error())
+		return
+	}
+
+	<-shutdown
+	logger.Log.Info("Server stopped")
+}
+
+// cacheStore добавляет перед хранилищем кэш ссылок в памяти процесса и в Redis, если
+// они включены в конфигурации
+func cacheStore(s store.Store) (store.Store, error) {
+	var backends []cache.Backend
+
+	if config.FlagURLCacheSize > 0 {
+		backends = append(backends, cache.NewLRU(config.FlagURLCacheSize))
+	}
+
+	if config.FlagRedisURL != "" {
+		options, err := redis.ParseURL(config.FlagRedisURL)
+
+		if err != nil {
+			return nil, err
+		}
+
+		backends = append(backends, cache.NewRedis(redis.NewClient(options), "shortener:url:"))
+	}
+
+	if len(backends) == 0 || config.FlagURLCacheTTL <= 0 {
+		return s, nil
+	}
+
+	return cache.NewStore(s, cache.Options{
+		TTL:         config.FlagURLCacheTTL,
+		NegativeTTL: config.FlagURLCacheNegativeTTL,
+	}, backends...), nil
 }
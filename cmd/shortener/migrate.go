@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	logger "github.com/laiker/shortener/internal"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"os"
+	"os/signal"
+)
+
+// migrateBatchSize число ссылок, которые переносятся одним вызовом хранилища
+const migrateBatchSize = 1000
+
+// migrateCommand выполняет подкоманду migrate-store: переносит ссылки из одного хранилища
+// в другое с их ID, владельцами и кодами. Перенос идёт в порядке ID, поэтому прерванный
+// перенос продолжается с последней ссылки, уже сохранённой в целевом хранилище.
+func migrateCommand(args []string) int {
+	fs := flag.NewFlagSet("migrate-store", flag.ContinueOnError)
+	from := fs.String("from", "", "Source store: file:path, sqlite://path, bolt://path or postgres://...")
+	to := fs.String("to", "", "Target store: file:path, sqlite://path, bolt://path or postgres://...")
+	batchSize := fs.Int("batch", migrateBatchSize, "Links saved per batch")
+	logLevel := fs.String("l", "error", "Log level")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *from == "" || *to == "" {
+		fs.Usage()
+		return 2
+	}
+
+	if err := logger.Initialize(*logLevel); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	source, closeSource, err := openStoreURL(ctx, *from)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	defer closeSource()
+
+	target, closeTarget, err := openStoreURL(ctx, *to)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	defer closeTarget()
+
+	migrated, err := migrateStore(ctx, source, target, *batchSize, func(lastID, migrated int) {
+		fmt.Printf("migrated %d, last id %d\n", migrated, lastID)
+	})
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("done: migrated %d, counts match\n", migrated)
+
+	return 0
+}
+
+// migrateStore переносит в target ссылки source, которых там ещё нет, и сверяет число
+// ссылок в хранилищах. После каждой пачки вызывает progress.
+func migrateStore(ctx context.Context, source, target store.Store, batchSize int, progress func(lastID, migrated int)) (int, error) {
+	if batchSize <= 0 {
+		batchSize = migrateBatchSize
+	}
+
+	stats, err := target.Stats(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	batch := make(json.BatchURLSlice, 0, batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := target.RestoreURLs(ctx, batch); err != nil {
+			return err
+		}
+
+		migrated += len(batch)
+
+		if progress != nil {
+			progress(batch[len(batch)-1].ID, migrated)
+		}
+
+		batch = batch[:0]
+
+		return nil
+	}
+
+	// ссылки с ID не больше последнего в target перенесены при прошлом запуске
+	err = source.Iterate(ctx, store.IterateOptions{AfterID: stats.LastID}, func(row json.DBRow) error {
+		batch = append(batch, row)
+
+		if len(batch) < batchSize {
+			return nil
+		}
+
+		return flush()
+	})
+
+	if err == nil {
+		err = flush()
+	}
+
+	if err != nil {
+		return migrated, err
+	}
+
+	return migrated, verifyMigration(ctx, source, target)
+}
+
+func verifyMigration(ctx context.Context, source, target store.Store) error {
+	sourceStats, err := source.Stats(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	targetStats, err := target.Stats(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if sourceStats != targetStats {
+		return fmt.Errorf("counts differ: source has %d links up to id %d, target has %d links up to id %d",
+			sourceStats.Count, sourceStats.LastID, targetStats.Count, targetStats.LastID)
+	}
+
+	return nil
+}
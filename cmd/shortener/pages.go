@@ -0,0 +1,121 @@
+package main
+
+import (
+	"github.com/laiker/shortener/cmd/config"
+	"github.com/laiker/shortener/internal/json"
+	"html/template"
+	"net/http"
+	"net/url"
+)
+
+// Режимы перехода по ссылке
+const (
+	modeDirect       = "direct"
+	modeInterstitial = "interstitial"
+)
+
+func validMode(mode string) bool {
+	return mode == "" || mode == modeDirect || mode == modeInterstitial
+}
+
+// linkMode возвращает режим ссылки, а если он не задан — режим из конфигурации
+func linkMode(row json.DBRow) string {
+	if row.Mode != "" {
+		return row.Mode
+	}
+
+	return config.FlagRedirectMode
+}
+
+var interstitialPage = template.Must(template.New("interstitial").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex, nofollow">
+<title>Redirect notice</title>
+</head>
+<body>
+<h1>You are leaving {{.ShortURL}}</h1>
+<p>You are being redirected to <code>{{.OriginalURL}}</code>.</p>
+<p>Make sure you trust {{.Host}} before continuing.</p>
+<p><a href="{{.OriginalURL}}" rel="noopener noreferrer nofollow">Continue to {{.Host}}</a></p>
+</body>
+</html>
+`))
+
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex, nofollow">
+<title>Link preview</title>
+</head>
+<body>
+<h1>Link preview</h1>
+<dl>
+<dt>Short link</dt><dd>{{.ShortURL}}</dd>
+<dt>Destination</dt><dd><a href="{{.OriginalURL}}" rel="noopener noreferrer nofollow">{{.OriginalURL}}</a></dd>
+<dt>Created</dt><dd>{{if .CreatedAt}}{{.CreatedAt}}{{else}}unknown{{end}}</dd>
+<dt>Clicks</dt><dd>{{if .Stored}}{{.Clicks}}{{else}}unknown{{end}}</dd>
+</dl>
+</body>
+</html>
+`))
+
+var passwordPage = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex, nofollow">
+<title>Password required</title>
+</head>
+<body>
+<h1>{{.ShortURL}} is protected</h1>
+{{if .Error}}<p><strong>{{.Error}}</strong></p>{{end}}
+<form method="post">
+<label>Password <input type="password" name="password" autofocus required></label>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+type pageData struct {
+	ShortURL    string
+	OriginalURL string
+	Host        string
+	CreatedAt   string
+	Clicks      int64
+	Stored      bool
+	Error       string
+}
+
+func newPageData(row json.DBRow, stored bool) pageData {
+	data := pageData{
+		ShortURL:    config.FlagOutputURL + "/" + row.ShortURL,
+		OriginalURL: row.OriginalURL,
+		Clicks:      row.Clicks,
+		Stored:      stored,
+	}
+
+	if uri, err := url.Parse(row.OriginalURL); err == nil {
+		data.Host = uri.Host
+	}
+
+	if row.CreatedAt != nil {
+		data.CreatedAt = row.CreatedAt.UTC().Format("2006-01-02 15:04 MST")
+	}
+
+	return data
+}
+
+func renderPage(w http.ResponseWriter, page *template.Template, data pageData) {
+	renderPageStatus(w, page, data, http.StatusOK)
+}
+
+func renderPageStatus(w http.ResponseWriter, page *template.Template, data pageData, status int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	page.Execute(w, data)
+}
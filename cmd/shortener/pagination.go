@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Размер страницы списка ссылок пользователя
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+var errWrongCursor = errors.New("wrong cursor")
+
+// encodeCursor упаковывает ключ последней ссылки страницы в непрозрачную строку.
+// Поле сортировки входит в курсор, чтобы его нельзя было применить к другой сортировке.
+func encodeCursor(sort string, row json.DBRow) string {
+	cursor := store.CursorOf(row)
+	value := cursor.CreatedAt.UnixNano()
+
+	if sort == store.SortClicks {
+		value = cursor.Clicks
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s.%d.%d", sort, value, cursor.ID)))
+}
+
+func decodeCursor(sort, encoded string) (*store.Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return nil, errWrongCursor
+	}
+
+	parts := strings.Split(string(data), ".")
+
+	if len(parts) != 3 || parts[0] != sort {
+		return nil, errWrongCursor
+	}
+
+	value, err := strconv.ParseInt(parts[1], 10, 64)
+
+	if err != nil {
+		return nil, errWrongCursor
+	}
+
+	id, err := strconv.Atoi(parts[2])
+
+	if err != nil {
+		return nil, errWrongCursor
+	}
+
+	cursor := &store.Cursor{ID: id}
+
+	if sort == store.SortClicks {
+		cursor.Clicks = value
+	} else {
+		cursor.CreatedAt = time.Unix(0, value)
+	}
+
+	return cursor, nil
+}
+
+// listOptions разбирает параметры списка ссылок: limit, sort (created или clicks),
+// order (asc или desc), q для поиска по адресу назначения, tag и cursor следующей страницы.
+// Без limit и cursor возвращается весь список, как до появления страниц
+func listOptions(query url.Values, userID string) (store.ListOptions, error) {
+	opts := store.ListOptions{
+		UserID: userID,
+		Sort:   store.SortCreated,
+		Desc:   true,
+		Search: strings.TrimSpace(query.Get("q")),
+	}
+
+	if query.Has("cursor") {
+		opts.Limit = defaultPageSize
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+
+		if err != nil || value <= 0 || value > maxPageSize {
+			return opts, errors.New("wrong limit")
+		}
+
+		opts.Limit = value
+	}
+
+	switch sort := query.Get("sort"); sort {
+	case "", store.SortCreated:
+	case store.SortClicks:
+		opts.Sort = sort
+	default:
+		return opts, errors.New("wrong sort")
+	}
+
+	switch order := query.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		opts.Desc = false
+	default:
+		return opts, errors.New("wrong order")
+	}
+
+	if query.Has("tag") {
+		tag, err := normalizeTag(query.Get("tag"))
+
+		if err != nil {
+			return opts, err
+		}
+
+		opts.Tag = tag
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := decodeCursor(opts.Sort, cursor)
+
+		if err != nil {
+			return opts, err
+		}
+
+		opts.After = after
+	}
+
+	return opts, nil
+}
+
+// setNextLink добавляет заголовок Link со ссылкой на страницу после row
+func setNextLink(w http.ResponseWriter, r *http.Request, opts store.ListOptions, row json.DBRow) {
+	query := r.URL.Query()
+	query.Set("cursor", encodeCursor(opts.Sort, row))
+
+	w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, query.Encode()))
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/laiker/shortener/cmd/config"
+	logger "github.com/laiker/shortener/internal"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/ratelimit"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// passwordHeader заголовок, в котором клиент может передать пароль ссылки без формы
+const passwordHeader = "X-Link-Password"
+
+// defaultPasswordRule ограничивает число неверных паролей для одной ссылки
+// значениями из конфигурации: FlagPasswordAttempts попыток за FlagPasswordAttemptsWindow
+func defaultPasswordRule() ratelimit.Rule {
+	rule := ratelimit.Rule{
+		Name:  "password",
+		Burst: config.FlagPasswordAttempts,
+	}
+
+	if config.FlagPasswordAttemptsWindow > 0 {
+		rule.Rate = float64(config.FlagPasswordAttempts) / config.FlagPasswordAttemptsWindow.Seconds()
+	}
+
+	return rule
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// generateCode возвращает случайный код ссылки. Коды защищённых ссылок не должны
+// раскрывать адрес назначения, как это делает base64 от адреса.
+func generateCode() (string, error) {
+	b := make([]byte, 8)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// linkPassword возвращает пароль из заголовка или из отправленной формы
+func linkPassword(r *http.Request) string {
+	if password := r.Header.Get(passwordHeader); password != "" {
+		return password
+	}
+
+	if r.Method == http.MethodPost {
+		return r.PostFormValue("password")
+	}
+
+	return ""
+}
+
+// checkPassword проверяет пароль защищённой ссылки. Если пароль не передан или неверен,
+// отвечает формой ввода пароля, а после исчерпания попыток — 429, и возвращает false.
+func (a *app) checkPassword(w http.ResponseWriter, r *http.Request, row json.DBRow) bool {
+	log := logger.FromContext(r.Context())
+	data := pageData{ShortURL: config.FlagOutputURL + "/" + row.ShortURL}
+
+	password := linkPassword(r)
+
+	if password == "" {
+		renderPageStatus(w, passwordPage, data, http.StatusUnauthorized)
+		return false
+	}
+
+	// попытку учитываем до проверки пароля, иначе параллельные неверные попытки
+	// пройдут проверку лимита раньше, чем будет учтена хотя бы одна из них
+	counted := false
+
+	if a.passwordRule.Enabled() {
+		result, err := a.limiter.Allow(r.Context(), row.ShortURL, a.passwordRule)
+
+		if err != nil {
+			log.Info("password limiter failed", zap.Error(err))
+		} else if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return false
+		}
+
+		counted = err == nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(row.PasswordHash), []byte(password)); err != nil {
+		log.Info("Wrong link password")
+
+		data.Error = "Wrong password"
+		renderPageStatus(w, passwordPage, data, http.StatusUnauthorized)
+		return false
+	}
+
+	// верный пароль попыткой подбора не считается
+	if counted {
+		if err := a.limiter.Refund(r.Context(), row.ShortURL, a.passwordRule); err != nil {
+			log.Info("password limiter failed", zap.Error(err))
+		}
+	}
+
+	return true
+}
+
+// passwordRedirectStatus выбирает код редиректа для защищённой ссылки. После отправки формы
+// отвечаем 303, чтобы браузер перешёл по адресу GET-запросом. Постоянные редиректы заменяем
+// временными: их кэшируют браузеры и прокси, и следующий переход прошёл бы без пароля.
+func passwordRedirectStatus(r *http.Request, status int) int {
+	if r.Method == http.MethodPost {
+		return http.StatusSeeOther
+	}
+
+	switch status {
+	case http.StatusMovedPermanently:
+		return http.StatusFound
+	case http.StatusPermanentRedirect:
+		return http.StatusTemporaryRedirect
+	}
+
+	return status
+}
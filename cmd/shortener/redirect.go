@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"github.com/laiker/shortener/cmd/config"
+	"github.com/laiker/shortener/internal/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Правила слияния параметров запроса с параметрами адреса назначения при совпадении имён
+const (
+	// queryOverride параметры запроса заменяют параметры назначения
+	queryOverride = "override"
+	// queryKeep параметры назначения сохраняются, совпадающие параметры запроса отбрасываются
+	queryKeep = "keep"
+	// queryAppend сохраняются значения и назначения, и запроса
+	queryAppend = "append"
+)
+
+func validQueryRule(rule string) bool {
+	return rule == queryOverride || rule == queryKeep || rule == queryAppend
+}
+
+// validRedirectType проверяет код ответа, который можно задать ссылке
+func validRedirectType(status int) bool {
+	switch status {
+	case 0, http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+
+	return false
+}
+
+// redirectStatus возвращает код ответа ссылки, а если он не задан — код из конфигурации
+func redirectStatus(row json.DBRow) int {
+	if row.RedirectType != 0 {
+		return row.RedirectType
+	}
+
+	return config.FlagRedirectStatus
+}
+
+// writeRedirect отвечает редиректом на location. Постоянные редиректы разрешаем кэшировать,
+// временные — нет, чтобы каждый переход доходил до сервиса и учитывался в статистике.
+func writeRedirect(w http.ResponseWriter, location string, status int) {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(config.FlagPermanentRedirectMaxAge.Seconds())))
+	default:
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+
+	w.Header().Set("Location", location)
+	w.WriteHeader(status)
+}
+
+// pathSuffix возвращает путь запроса после кода ссылки в исходном кодировании
+func pathSuffix(r *http.Request) string {
+	rest := strings.TrimPrefix(r.URL.EscapedPath(), "/")
+
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		return rest[i+1:]
+	}
+
+	return ""
+}
+
+// passthroughURL дописывает к адресу назначения путь suffix и сливает параметры rawQuery по правилу rule.
+// Кодирование пути и параметров сохраняется как в исходных адресах.
+func passthroughURL(destination, suffix, rawQuery, rule string) (string, error) {
+	uri, err := url.Parse(destination)
+
+	if err != nil {
+		return "", err
+	}
+
+	if suffix != "" {
+		escaped := strings.TrimSuffix(uri.EscapedPath(), "/") + "/" + suffix
+
+		path, err := url.PathUnescape(escaped)
+
+		if err != nil {
+			return "", err
+		}
+
+		uri.Path = path
+		uri.RawPath = escaped
+	}
+
+	if rawQuery != "" {
+		uri.RawQuery = mergeQuery(uri.RawQuery, rawQuery, rule)
+	}
+
+	return uri.String(), nil
+}
+
+type queryParam struct {
+	name string
+	raw  string
+}
+
+func splitQuery(rawQuery string) []queryParam {
+	var params []queryParam
+
+	for _, raw := range strings.Split(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+
+		key, _, _ := strings.Cut(raw, "=")
+		name, err := url.QueryUnescape(key)
+
+		if err != nil {
+			name = key
+		}
+
+		params = append(params, queryParam{name: name, raw: raw})
+	}
+
+	return params
+}
+
+func mergeQuery(destination, request, rule string) string {
+	destParams := splitQuery(destination)
+	requestParams := splitQuery(request)
+
+	names := func(params []queryParam) map[string]bool {
+		set := make(map[string]bool, len(params))
+		for _, p := range params {
+			set[p.name] = true
+		}
+		return set
+	}
+
+	var merged []queryParam
+
+	switch rule {
+	case queryKeep:
+		destNames := names(destParams)
+		merged = append(merged, destParams...)
+		for _, p := range requestParams {
+			if !destNames[p.name] {
+				merged = append(merged, p)
+			}
+		}
+	case queryAppend:
+		merged = append(append(merged, destParams...), requestParams...)
+	default:
+		requestNames := names(requestParams)
+		for _, p := range destParams {
+			if !requestNames[p.name] {
+				merged = append(merged, p)
+			}
+		}
+		merged = append(merged, requestParams...)
+	}
+
+	raws := make([]string, len(merged))
+	for i, p := range merged {
+		raws[i] = p.raw
+	}
+
+	return strings.Join(raws, "&")
+}
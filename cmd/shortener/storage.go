@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/laiker/shortener/cmd/config"
+	logger "github.com/laiker/shortener/internal"
+	"github.com/laiker/shortener/internal/store"
+	"github.com/laiker/shortener/internal/store/file"
+	"github.com/laiker/shortener/internal/store/kv"
+	"github.com/laiker/shortener/internal/store/memory"
+	"github.com/laiker/shortener/internal/store/mirror"
+	"github.com/laiker/shortener/internal/store/pg"
+	"github.com/laiker/shortener/internal/store/sqlite"
+	"github.com/laiker/shortener/internal/tracing"
+	"go.uber.org/zap"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// passwordPattern пароль в DSN PostgreSQL в формате ключ=значение
+var passwordPattern = regexp.MustCompile(`password=\S+`)
+
+// storageURL возвращает адрес основного хранилища: STORAGE_URL или, если он не задан,
+// DSN базы данных, затем файл хранилища, затем память
+func storageURL() string {
+	switch {
+	case config.FlagStorageURL != "":
+		return config.FlagStorageURL
+	case config.DatabaseDsn != "":
+		return config.DatabaseDsn
+	case config.StoragePath != "":
+		return "file://" + config.StoragePath
+	}
+
+	return "memory://"
+}
+
+// parseStoreURL разбирает адрес хранилища: memory://, file:путь, sqlite://путь, bolt://путь
+// или DSN PostgreSQL в виде postgres://... или ключ=значение
+func parseStoreURL(address string) (backend, target string, err error) {
+	switch {
+	case address == "memory://":
+		return "memory", "", nil
+	case strings.HasPrefix(address, "file:"):
+		// file:///tmp/x и file:/tmp/x указывают на один файл
+		target = strings.TrimPrefix(strings.TrimPrefix(address, "file:"), "//")
+
+		if target == "" {
+			return "", "", fmt.Errorf("empty file path in %q", address)
+		}
+
+		return "file", target, nil
+	case strings.HasPrefix(address, config.SQLiteScheme):
+		path, _ := config.DSNPath(address, config.SQLiteScheme)
+		return "sqlite", path, nil
+	case strings.HasPrefix(address, config.BoltScheme):
+		path, _ := config.DSNPath(address, config.BoltScheme)
+		return "bolt", path, nil
+	case strings.HasPrefix(address, "postgres://"), strings.HasPrefix(address, "postgresql://"):
+		return "postgres", address, nil
+	case !strings.Contains(address, "://") && strings.Contains(address, "="):
+		return "postgres", address, nil
+	}
+
+	return "", "", fmt.Errorf("unknown store address %q", address)
+}
+
+// redactAddress скрывает пароль в адресе хранилища перед записью в лог
+func redactAddress(address string) string {
+	if u, err := url.Parse(address); err == nil && u.Scheme != "" {
+		return u.Redacted()
+	}
+
+	return passwordPattern.ReplaceAllString(address, "password=xxxxx")
+}
+
+// mirrorFlushTimeout сколько закрытие хранилища ждёт записи очереди в зеркало
+const mirrorFlushTimeout = 5 * time.Second
+
+// openStore создаёт и подготавливает хранилище по адресу storageURL и, если задан
+// STORAGE_MIRROR_URL, зеркало, в котором повторяются его изменения. Пул соединений
+// основной БД возвращается, чтобы его могли использовать и другие компоненты.
+// Возвращаемая функция дописывает очередь зеркала и закрывает соединения.
+func openStore(ctx context.Context) (store.Store, *pgxpool.Pool, func(), error) {
+	address := storageURL()
+	backend, target, err := parseStoreURL(address)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	replicaDsn := ""
+
+	if backend == "postgres" {
+		replicaDsn = config.DatabaseReplicaDsn
+	}
+
+	cstore, db, closeStore, err := openBackend(ctx, backend, target, replicaDsn)
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	fields := []zap.Field{zap.String("backend", backend), zap.String("url", redactAddress(address))}
+
+	if replicaDsn != "" {
+		fields = append(fields, zap.String("replica", redactAddress(replicaDsn)))
+	}
+
+	if config.FlagStorageMirrorURL != "" {
+		mirrorStore, closeMirror, err := openStoreURL(ctx, config.FlagStorageMirrorURL)
+
+		if err != nil {
+			closeStore()
+			return nil, nil, nil, fmt.Errorf("mirror store: %w", err)
+		}
+
+		replicated := mirror.NewStore(cstore, mirrorStore, mirror.Options{
+			QueueSize:     config.FlagStorageMirrorQueue,
+			RetryInterval: config.FlagStorageMirrorRetry,
+			CheckInterval: config.FlagStorageCheckInterval,
+		})
+
+		runCtx, stop := context.WithCancel(context.Background())
+		done := make(chan struct{})
+
+		go func() {
+			replicated.Run(runCtx)
+			close(done)
+		}()
+
+		closePrimary := closeStore
+		closeStore = func() {
+			flushCtx, cancel := context.WithTimeout(context.Background(), mirrorFlushTimeout)
+			defer cancel()
+
+			if err := replicated.Flush(flushCtx); err != nil {
+				logger.Log.Info("Mirror is behind", zap.Int("pending", replicated.Pending()))
+			}
+
+			stop()
+			<-done
+			closeMirror()
+			closePrimary()
+		}
+
+		cstore = replicated
+		fields = append(fields, zap.String("mirror", redactAddress(config.FlagStorageMirrorURL)))
+	}
+
+	logger.Log.Info("Storage selected", fields...)
+
+	return cstore, db, closeStore, nil
+}
+
+// openStoreURL создаёт и подготавливает хранилище по адресу address. Возвращаемая
+// функция закрывает соединения с ним
+func openStoreURL(ctx context.Context, address string) (store.Store, func(), error) {
+	backend, target, err := parseStoreURL(address)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cstore, _, closeStore, err := openBackend(ctx, backend, target, "")
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cstore, closeStore, nil
+}
+
+// openBackend создаёт и подготавливает хранилище backend: memory, file, sqlite или bolt
+// с файлом target или postgres с DSN target. Если задан replicaDsn, postgres читает ссылки
+// с реплики. Возвращаемая функция закрывает соединения.
+func openBackend(ctx context.Context, backend, target, replicaDsn string) (store.Store, *pgxpool.Pool, func(), error) {
+	var db *pgxpool.Pool
+	var cstore store.Store
+	closeStore := func() {}
+
+	switch backend {
+	case "memory":
+		cstore = memory.NewStore()
+	case "file":
+		cstore = file.NewStore(target)
+	case "postgres":
+		var err error
+		db, err = newPool(target)
+
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		closeStore = db.Close
+
+		retry := pg.RetryOptions{
+			Retries:    config.FlagDatabaseRetries,
+			Backoff:    config.FlagDatabaseRetryBackoff,
+			MaxBackoff: pg.DefaultRetry.MaxBackoff,
+		}
+
+		if replicaDsn == "" {
+			pgStore := pg.NewStore(db)
+			pgStore.SetRetry(retry)
+			cstore = pgStore
+			break
+		}
+
+		replicaDB, err := newPool(replicaDsn)
+
+		if err != nil {
+			db.Close()
+			return nil, nil, nil, fmt.Errorf("replica: %w", err)
+		}
+
+		pgStore := pg.NewStoreWithReplica(db, replicaDB, pg.ReplicaOptions{
+			Window:        config.FlagReplicaReadWindow,
+			CheckInterval: config.FlagStorageCheckInterval,
+		})
+		pgStore.SetRetry(retry)
+
+		runCtx, stop := context.WithCancel(context.Background())
+		done := make(chan struct{})
+
+		go func() {
+			pgStore.Run(runCtx)
+			close(done)
+		}()
+
+		closeStore = func() {
+			stop()
+			<-done
+			replicaDB.Close()
+			db.Close()
+		}
+
+		cstore = pgStore
+	case "sqlite":
+		conn, err := sqlite.Open(target)
+
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		closeStore = func() { conn.Close() }
+		cstore = sqlite.NewStore(conn)
+	case "bolt":
+		conn, err := kv.Open(target)
+
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		closeStore = func() { conn.Close() }
+		cstore = kv.NewStore(conn)
+	default:
+		return nil, nil, nil, fmt.Errorf("unknown store %q", backend)
+	}
+
+	cstore = tracing.NewStore(cstore, backend)
+
+	bootstrapCtx, cancel := migrationContext(ctx)
+	defer cancel()
+
+	if err := cstore.Bootstrap(bootstrapCtx); err != nil {
+		closeStore()
+		return nil, nil, nil, err
+	}
+
+	return cstore, db, closeStore, nil
+}
+
+// migrationContext возвращает контекст для Bootstrap со значениями ctx, но без его срока:
+// миграции большой таблицы идут дольше, чем запрос, и ограничиваются только migration-timeout
+func migrationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = context.WithoutCancel(ctx)
+
+	if config.FlagMigrationTimeout > 0 {
+		return context.WithTimeout(ctx, config.FlagMigrationTimeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+// newPool создаёт пул соединений с PostgreSQL с трассировкой запросов и настройками
+// пула из конфигурации
+func newPool(dsn string) (*pgxpool.Pool, error) {
+	poolConfig, err := parsePoolConfig(dsn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgxpool.NewWithConfig(context.Background(), poolConfig)
+}
+
+// parsePoolConfig разбирает DSN и применяет к нему настройки пула из конфигурации
+func parsePoolConfig(dsn string) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	poolConfig.ConnConfig.Tracer = tracing.QueryTracer{}
+
+	if config.FlagDatabaseMaxConns > 0 {
+		poolConfig.MaxConns = int32(config.FlagDatabaseMaxConns)
+	}
+
+	if config.FlagDatabaseMinConns > 0 {
+		poolConfig.MinConns = int32(config.FlagDatabaseMinConns)
+	}
+
+	if poolConfig.MinConns > poolConfig.MaxConns {
+		return nil, fmt.Errorf("database min conns %d exceed max conns %d", poolConfig.MinConns, poolConfig.MaxConns)
+	}
+
+	if config.FlagDatabaseConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = config.FlagDatabaseConnLifetime
+	}
+
+	if config.FlagDatabaseConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = config.FlagDatabaseConnIdleTime
+	}
+
+	if config.FlagDatabaseStatementTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(config.FlagDatabaseStatementTimeout.Milliseconds(), 10)
+	}
+
+	return poolConfig, nil
+}
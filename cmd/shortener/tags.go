@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"github.com/go-chi/chi"
+	"github.com/laiker/shortener/cmd/config"
+	logger "github.com/laiker/shortener/internal"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"github.com/mailru/easyjson"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Ограничения на теги одной ссылки
+const (
+	maxTags       = 32
+	maxTagLength  = 64
+	errorTagsText = "wrong tags"
+)
+
+// normalizeTags приводит теги к нижнему регистру и убирает повторы, сохраняя порядок
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) > maxTags {
+		return nil, errors.New(errorTagsText)
+	}
+
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+
+		if tag == "" || len(tag) > maxTagLength || strings.ContainsAny(tag, ",/") {
+			return nil, errors.New(errorTagsText)
+		}
+
+		if seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		result = append(result, tag)
+	}
+
+	return result, nil
+}
+
+// normalizeTag приводит к виду normalizeTags один тег, например из адреса запроса
+func normalizeTag(tag string) (string, error) {
+	tags, err := normalizeTags([]string{tag})
+
+	if err != nil {
+		return "", err
+	}
+
+	return tags[0], nil
+}
+
+func (a *app) tagsHandler(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	log.Info("tagsHandler")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _ := r.Context().Value(config.UserIDKey).(string)
+
+	if userID == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	urls, err := a.store.GetUserURLs(r.Context(), userID)
+
+	if err != nil {
+		log.Info("Get user urls failed", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	counts := make(map[string]int)
+
+	for _, row := range urls {
+		for _, tag := range row.Tags {
+			counts[tag]++
+		}
+	}
+
+	result := make(json.TagSlice, 0, len(counts))
+
+	for name, count := range counts {
+		result = append(result, json.Tag{Name: name, Count: count})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	response, err := easyjson.Marshal(result)
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// tagHandler переименовывает (PATCH) или удаляет (DELETE) тег на всех ссылках пользователя
+func (a *app) tagHandler(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+	log.Info("tagHandler")
+
+	if r.Method != http.MethodPatch && r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, _ := r.Context().Value(config.UserIDKey).(string)
+
+	if userID == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	tag, err := normalizeTag(chi.URLParam(r, "tag"))
+
+	if err != nil {
+		http.Error(w, "Error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		err = a.store.DeleteTag(r.Context(), userID, tag)
+	} else {
+		body, errread := io.ReadAll(r.Body)
+		defer r.Body.Close()
+
+		rename := json.TagRename{}
+
+		if errread != nil || easyjson.Unmarshal(body, &rename) != nil {
+			log.Info("Bad Request can't unmarshal")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		newName, errname := normalizeTag(rename.Name)
+
+		if errname != nil {
+			http.Error(w, "Error: "+errname.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = a.store.RenameTag(r.Context(), userID, tag, newName)
+	}
+
+	if errors.Is(err, store.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		log.Info("Tag update failed", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
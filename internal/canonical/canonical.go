@@ -0,0 +1,162 @@
+package canonical
+
+import (
+	"golang.org/x/net/idna"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultTrackingParams параметры, которые удаляются при включённом StripTracking.
+// Шаблон с * на конце совпадает с любым параметром с таким префиксом.
+var DefaultTrackingParams = []string{"utm_*", "fbclid", "gclid", "yclid", "mc_cid", "mc_eid"}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Options настройки приведения адресов к каноническому виду
+type Options struct {
+	// StripTracking удаляет параметры отслеживания из TrackingParams
+	StripTracking  bool
+	TrackingParams []string
+	// StripTrailingSlash удаляет завершающий слэш из непустого пути
+	StripTrailingSlash bool
+	// SortQuery упорядочивает параметры запроса по имени
+	SortQuery bool
+}
+
+// Canonicalizer приводит адреса назначения к единому виду, чтобы одинаковые ссылки
+// сохранялись и находились как дубликаты одной записью
+type Canonicalizer struct {
+	opts Options
+}
+
+// New возвращает Canonicalizer с настройками opts
+func New(opts Options) *Canonicalizer {
+	return &Canonicalizer{opts: opts}
+}
+
+// Canonicalize возвращает копию адреса в каноническом виде: схема и хост в нижнем регистре,
+// IDN домен в punycode, без порта по умолчанию и с учётом настроек пути и запроса
+func (c *Canonicalizer) Canonicalize(u *url.URL) (*url.URL, error) {
+	result := *u
+	result.Scheme = strings.ToLower(u.Scheme)
+
+	if u.Host != "" {
+		host, err := c.host(result.Scheme, u)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result.Host = host
+	}
+
+	// для http пустой путь и корень равнозначны
+	if result.Path == "/" && result.RawPath == "" {
+		result.Path = ""
+	}
+
+	if c.opts.StripTrailingSlash && len(result.Path) > 1 && strings.HasSuffix(result.Path, "/") {
+		result.Path = strings.TrimRight(result.Path, "/")
+		result.RawPath = strings.TrimRight(result.RawPath, "/")
+	}
+
+	if c.opts.StripTracking || c.opts.SortQuery {
+		result.RawQuery = c.query(u.RawQuery)
+		result.ForceQuery = false
+	}
+
+	return &result, nil
+}
+
+func (c *Canonicalizer) host(scheme string, u *url.URL) (string, error) {
+	hostname := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	port := u.Port()
+
+	if port == defaultPorts[scheme] {
+		port = ""
+	}
+
+	if ip := net.ParseIP(hostname); ip != nil {
+		if strings.Contains(hostname, ":") {
+			hostname = "[" + ip.String() + "]"
+		}
+	} else {
+		ascii, err := idna.Lookup.ToASCII(hostname)
+
+		if err != nil {
+			return "", err
+		}
+
+		hostname = ascii
+	}
+
+	if port != "" {
+		return hostname + ":" + port, nil
+	}
+
+	return hostname, nil
+}
+
+// query фильтрует и сортирует параметры, сохраняя их исходное кодирование
+func (c *Canonicalizer) query(rawQuery string) string {
+	type param struct {
+		name string
+		raw  string
+	}
+
+	var params []param
+
+	for _, raw := range strings.Split(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+
+		key, _, _ := strings.Cut(raw, "=")
+		name, err := url.QueryUnescape(key)
+
+		if err != nil {
+			name = key
+		}
+
+		if c.opts.StripTracking && c.isTracking(name) {
+			continue
+		}
+
+		params = append(params, param{name: name, raw: raw})
+	}
+
+	if c.opts.SortQuery {
+		// порядок значений одного параметра может быть значим, поэтому сортировка устойчивая
+		sort.SliceStable(params, func(i, j int) bool {
+			return params[i].name < params[j].name
+		})
+	}
+
+	raws := make([]string, len(params))
+	for i, p := range params {
+		raws[i] = p.raw
+	}
+
+	return strings.Join(raws, "&")
+}
+
+func (c *Canonicalizer) isTracking(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, pattern := range c.opts.TrackingParams {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+
+	return false
+}
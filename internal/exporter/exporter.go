@@ -0,0 +1,132 @@
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	json2 "encoding/json"
+	"errors"
+	"fmt"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Форматы выгрузки ссылок, в них же importer читает загружаемые ссылки
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// csvHeader столбцы выгрузки в CSV
+var csvHeader = []string{"short_url", "original_url", "created_at", "clicks", "redirect_type", "expires_at", "tags"}
+
+// Options настройки выгрузки
+type Options struct {
+	// Format формат выгрузки: FormatCSV или FormatJSONL
+	Format string
+	// UserID выгружает только ссылки пользователя, пустой выгружает все
+	UserID string
+	// Transform изменяет ссылку перед записью, например убирает служебные поля
+	Transform func(row json.DBRow) json.DBRow
+}
+
+// ContentType возвращает MIME тип выгрузки в формате format
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+
+	return "application/x-ndjson"
+}
+
+// Export потоково пишет в dst ссылки из s и возвращает их число. Строки JSON lines
+// совпадают с форматом file.Store, поэтому выгрузку можно открыть как файл хранилища.
+func Export(ctx context.Context, s store.Store, dst io.Writer, opts Options) (int, error) {
+	buffered := bufio.NewWriter(dst)
+
+	write, err := newWriter(buffered, opts.Format)
+
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	err = s.Iterate(ctx, store.IterateOptions{UserID: opts.UserID}, func(row json.DBRow) error {
+		if opts.Transform != nil {
+			row = opts.Transform(row)
+		}
+
+		count++
+
+		return write(row)
+	})
+
+	if err != nil {
+		return count, err
+	}
+
+	return count, buffered.Flush()
+}
+
+func newWriter(w *bufio.Writer, format string) (func(row json.DBRow) error, error) {
+	switch format {
+	case FormatJSONL:
+		encoder := json2.NewEncoder(w)
+
+		return func(row json.DBRow) error {
+			return encoder.Encode(row)
+		}, nil
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+
+		if err := writer.Write(csvHeader); err != nil {
+			return nil, err
+		}
+
+		return func(row json.DBRow) error {
+			if err := writer.Write(csvRecord(row)); err != nil {
+				return err
+			}
+
+			// csv.Writer буферизует сам, сбрасываем его в общий буфер после каждой строки
+			writer.Flush()
+
+			return writer.Error()
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+func csvRecord(row json.DBRow) []string {
+	record := []string{
+		row.ShortURL,
+		row.OriginalURL,
+		"",
+		strconv.FormatInt(row.Clicks, 10),
+		"",
+		"",
+		strings.Join(row.Tags, ";"),
+	}
+
+	if row.CreatedAt != nil {
+		record[2] = row.CreatedAt.UTC().Format(time.RFC3339)
+	}
+
+	if row.RedirectType != 0 {
+		record[4] = strconv.Itoa(row.RedirectType)
+	}
+
+	if row.ExpiresAt != nil {
+		record[5] = row.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	return record
+}
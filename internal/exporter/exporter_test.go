@@ -0,0 +1,78 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	json2 "encoding/json"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"github.com/laiker/shortener/internal/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newStore(t *testing.T) store.Store {
+	ctx := context.Background()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	s := memory.NewStore()
+	require.NoError(t, s.Bootstrap(ctx))
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{
+		ShortURL:     "a",
+		OriginalURL:  "https://export.ru/a,b",
+		UserID:       "alice",
+		CreatedAt:    &created,
+		RedirectType: 301,
+		Tags:         []string{"promo", "q4"},
+	}))
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "b", OriginalURL: "https://export.ru/b", UserID: "bob", CreatedAt: &created}))
+	require.NoError(t, s.IncrementClicks(ctx, "a"))
+
+	return s
+}
+
+func TestExportCSV(t *testing.T) {
+	var out bytes.Buffer
+
+	count, err := Export(context.Background(), newStore(t), &out, Options{Format: FormatCSV, UserID: "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "short_url,original_url,created_at,clicks,redirect_type,expires_at,tags\n"+
+		"a,\"https://export.ru/a,b\",2024-01-02T03:04:05Z,1,301,,promo;q4\n", out.String())
+}
+
+func TestExportJSONL(t *testing.T) {
+	var out bytes.Buffer
+
+	count, err := Export(context.Background(), newStore(t), &out, Options{
+		Format: FormatJSONL,
+		Transform: func(row json.DBRow) json.DBRow {
+			row.UserID = ""
+			return row
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+
+	var codes []string
+
+	for _, line := range lines {
+		row := json.DBRow{}
+		require.NoError(t, json2.Unmarshal([]byte(line), &row))
+		assert.Empty(t, row.UserID)
+		codes = append(codes, row.ShortURL)
+	}
+
+	assert.Equal(t, []string{"a", "b"}, codes)
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	_, err := Export(context.Background(), memory.NewStore(), &bytes.Buffer{}, Options{Format: "xml"})
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
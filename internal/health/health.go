@@ -0,0 +1,109 @@
+// Package health собирает состояние компонентов сервиса для проб liveness и readiness
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK = "ok"
+	// StatusDegraded компонент неисправен, но сервис продолжает отвечать
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+// Check проверяет компонент. Ошибка переводит его в StatusDown, details попадают в ответ
+type Check func(ctx context.Context) (details map[string]any, err error)
+
+// Component результат проверки компонента
+type Component struct {
+	Status  string         `json:"status"`
+	Error   string         `json:"error,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+	// Critical без компонента сервис не готов принимать запросы
+	Critical bool `json:"critical"`
+	// DurationMs время проверки в миллисекундах
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// Report ответ пробы: общее состояние и состояние каждого компонента
+type Report struct {
+	Status     string               `json:"status"`
+	Components map[string]Component `json:"components"`
+}
+
+type check struct {
+	name     string
+	critical bool
+	fn       Check
+}
+
+// Checker хранит проверки компонентов и выполняет их параллельно
+type Checker struct {
+	checks []check
+}
+
+// Add добавляет проверку компонента name. Отказ критичного компонента переводит весь сервис
+// в StatusDown, некритичного — в StatusDegraded
+func (c *Checker) Add(name string, critical bool, fn Check) {
+	c.checks = append(c.checks, check{name: name, critical: critical, fn: fn})
+}
+
+// Run выполняет все проверки, каждую не дольше timeout
+func (c *Checker) Run(ctx context.Context, timeout time.Duration) Report {
+	report := Report{Status: StatusOK, Components: make(map[string]Component, len(c.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, ch := range c.checks {
+		wg.Add(1)
+
+		go func(ch check) {
+			defer wg.Done()
+
+			component := run(ctx, ch, timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Components[ch.name] = component
+
+			switch {
+			case component.Status == StatusOK:
+			case ch.critical:
+				report.Status = StatusDown
+			case report.Status == StatusOK:
+				report.Status = StatusDegraded
+			}
+		}(ch)
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+func run(ctx context.Context, ch check, timeout time.Duration) Component {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := ch.fn(ctx)
+
+	component := Component{
+		Status:     StatusOK,
+		Details:    details,
+		Critical:   ch.critical,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+
+	if err != nil {
+		component.Status = StatusDown
+		component.Error = err.Error()
+	}
+
+	return component
+}
@@ -0,0 +1,51 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func ok(ctx context.Context) (map[string]any, error) {
+	return map[string]any{"n": 1}, nil
+}
+
+func fail(ctx context.Context) (map[string]any, error) {
+	return nil, errors.New("boom")
+}
+
+func TestChecker(t *testing.T) {
+	checker := &Checker{}
+	checker.Add("store", true, ok)
+
+	report := checker.Run(context.Background(), time.Second)
+	assert.Equal(t, StatusOK, report.Status)
+	assert.Equal(t, map[string]any{"n": 1}, report.Components["store"].Details)
+
+	// некритичный отказ сервис не останавливает
+	checker.Add("cache", false, fail)
+
+	report = checker.Run(context.Background(), time.Second)
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.Equal(t, StatusDown, report.Components["cache"].Status)
+	assert.Equal(t, "boom", report.Components["cache"].Error)
+
+	checker.Add("file", true, fail)
+
+	report = checker.Run(context.Background(), time.Second)
+	assert.Equal(t, StatusDown, report.Status)
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	checker := &Checker{}
+	checker.Add("slow", true, func(ctx context.Context) (map[string]any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	report := checker.Run(context.Background(), 10*time.Millisecond)
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Components["slow"].Error)
+}
@@ -0,0 +1,326 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/laiker/shortener/internal/exporter"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultBatchSize число ссылок, которые сохраняются одним вызовом хранилища
+const DefaultBatchSize = 1000
+
+// maxAliasLength ограничивает длину сохраняемых кодов
+const maxAliasLength = 64
+
+var ErrUnknownFormat = errors.New("unknown import format")
+
+// Options настройки импорта
+type Options struct {
+	// Format формат выгрузки: exporter.FormatCSV или exporter.FormatJSONL
+	Format    string
+	BatchSize int
+	// Owner владелец ссылок, для которых он не указан
+	Owner string
+	// Prepare проверяет адрес назначения и приводит его к каноническому виду
+	Prepare func(ctx context.Context, uri *url.URL) (*url.URL, error)
+	// Encode возвращает код для ссылок без alias
+	Encode func(destination string) string
+	// Decode возвращает адрес, которому Encode выдаёт код code
+	Decode func(code string) (string, error)
+	// Reserved коды, занятые маршрутами сервиса: ссылка с таким кодом была бы недоступна
+	Reserved []string
+	// Reject вызывается для каждой отклонённой строки
+	Reject func(rejection json.Rejection)
+}
+
+// FormatOf определяет формат выгрузки по расширению файла
+func FormatOf(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return exporter.FormatCSV
+	case ".jsonl", ".ndjson", ".json":
+		return exporter.FormatJSONL
+	}
+
+	return ""
+}
+
+// reader читает ссылки из выгрузки по одной. Ошибка rowError относится только к строке line,
+// после неё чтение можно продолжать, любая другая ошибка прерывает импорт.
+type reader interface {
+	next() (line int, row json.ImportRow, err error)
+}
+
+type rowError struct {
+	reason string
+}
+
+func (e rowError) Error() string {
+	return e.reason
+}
+
+func newReader(src io.Reader, format string) (reader, error) {
+	switch format {
+	case exporter.FormatCSV:
+		return newCSVReader(src), nil
+	case exporter.FormatJSONL:
+		return newJSONLReader(src), nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+// pending строка, ожидающая сохранения в пачке
+type pending struct {
+	line int
+	row  json.DBRow
+}
+
+// Import потоково читает выгрузку src, проверяет каждую строку и сохраняет ссылки в s
+// пачками по opts.BatchSize. Отклонённые строки передаются в opts.Reject и не прерывают импорт.
+func Import(ctx context.Context, src io.Reader, s store.Store, opts Options) (json.ImportReport, error) {
+	report := json.ImportReport{}
+
+	r, err := newReader(src, opts.Format)
+
+	if err != nil {
+		return report, err
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	reject := func(line int, row json.ImportRow, reason string) {
+		report.Rejected++
+
+		if opts.Reject != nil {
+			opts.Reject(json.Rejection{Line: line, Alias: row.Alias, Destination: row.Destination, Reason: reason})
+		}
+	}
+
+	batch := make([]pending, 0, opts.BatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		rows := make(json.BatchURLSlice, len(batch))
+
+		for i := range batch {
+			rows[i] = batch[i].row
+		}
+
+		skipped, err := s.ImportURLs(ctx, rows)
+
+		if err != nil {
+			return err
+		}
+
+		rejectSkipped(batch, skipped, func(p pending) {
+			reject(p.line, json.ImportRow{Alias: p.row.ShortURL, Destination: p.row.OriginalURL}, "alias already exists")
+		})
+
+		report.Imported += len(batch) - len(skipped)
+		batch = batch[:0]
+
+		return nil
+	}
+
+	for {
+		line, record, err := r.next()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var rowErr rowError
+
+		if errors.As(err, &rowErr) {
+			report.Read++
+			reject(line, record, rowErr.reason)
+			continue
+		}
+
+		if err != nil {
+			return report, err
+		}
+
+		report.Read++
+
+		row, err := validate(ctx, record, opts)
+
+		if err != nil {
+			reject(line, record, err.Error())
+			continue
+		}
+
+		batch = append(batch, pending{line: line, row: row})
+
+		if len(batch) < opts.BatchSize {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		if err := flush(); err != nil {
+			return report, err
+		}
+	}
+
+	return report, flush()
+}
+
+// rejectSkipped находит строки пачки, коды которых хранилище пропустило. Из повторов
+// кода в пачке сохраняется первый, поэтому отклоняются последние вхождения.
+func rejectSkipped(batch []pending, skipped []string, reject func(p pending)) {
+	if len(skipped) == 0 {
+		return
+	}
+
+	skippedCount := make(map[string]int, len(skipped))
+
+	for _, code := range skipped {
+		skippedCount[code]++
+	}
+
+	total := make(map[string]int, len(skippedCount))
+
+	for _, p := range batch {
+		if _, ok := skippedCount[p.row.ShortURL]; ok {
+			total[p.row.ShortURL]++
+		}
+	}
+
+	seen := make(map[string]int, len(total))
+
+	for _, p := range batch {
+		code := p.row.ShortURL
+		count, ok := skippedCount[code]
+
+		if !ok {
+			continue
+		}
+
+		seen[code]++
+
+		if seen[code] > total[code]-count {
+			reject(p)
+		}
+	}
+}
+
+// validate проверяет строку выгрузки и превращает её в ссылку для хранилища
+func validate(ctx context.Context, record json.ImportRow, opts Options) (json.DBRow, error) {
+	row := json.DBRow{}
+
+	uri, err := url.ParseRequestURI(strings.TrimSpace(record.Destination))
+
+	if err != nil {
+		return row, errors.New("wrong destination")
+	}
+
+	if opts.Prepare != nil {
+		if uri, err = opts.Prepare(ctx, uri); err != nil {
+			return row, err
+		}
+	}
+
+	row.OriginalURL = uri.String()
+
+	row.ShortURL = strings.TrimSpace(record.Alias)
+
+	switch {
+	case row.ShortURL != "" && !validAlias(row.ShortURL):
+		return row, errors.New("wrong alias")
+	case row.ShortURL != "" && reservedAlias(row.ShortURL, row.OriginalURL, opts):
+		return row, errors.New("alias is reserved")
+	case row.ShortURL == "" && opts.Encode == nil:
+		return row, errors.New("alias is required")
+	case row.ShortURL == "":
+		row.ShortURL = opts.Encode(row.OriginalURL)
+	}
+
+	if createdAt := strings.TrimSpace(record.CreatedAt); createdAt != "" {
+		created, err := parseTime(createdAt)
+
+		if err != nil {
+			return row, errors.New("wrong created_at")
+		}
+
+		row.CreatedAt = &created
+	}
+
+	row.UserID = strings.TrimSpace(record.Owner)
+
+	if row.UserID == "" {
+		row.UserID = opts.Owner
+	}
+
+	if row.UserID == "" {
+		return row, errors.New("owner is required")
+	}
+
+	return row, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse("2006-01-02", value)
+}
+
+// reservedAlias сообщает, что alias занят маршрутом сервиса или совпадает с кодом, который Encode
+// выдаёт другому адресу. Такой alias перехватил бы будущие ссылки на тот адрес
+func reservedAlias(alias, destination string, opts Options) bool {
+	for _, reserved := range opts.Reserved {
+		if alias == reserved {
+			return true
+		}
+	}
+
+	if opts.Decode == nil {
+		return false
+	}
+
+	decoded, err := opts.Decode(alias)
+
+	if err != nil || decoded == destination {
+		return false
+	}
+
+	_, err = url.ParseRequestURI(decoded)
+
+	return err == nil
+}
+
+// validAlias пропускает коды из незарезервированных символов URL, которые не нужно кодировать в пути
+func validAlias(alias string) bool {
+	if len(alias) > maxAliasLength {
+		return false
+	}
+
+	for _, c := range alias {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == '~':
+		default:
+			return false
+		}
+	}
+
+	return alias != "." && alias != ".."
+}
@@ -0,0 +1,104 @@
+package importer
+
+import (
+	"context"
+	"github.com/laiker/shortener/internal/exporter"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestRejectSkipped(t *testing.T) {
+	batch := []pending{
+		{line: 1, row: json.DBRow{ShortURL: "a"}},
+		{line: 2, row: json.DBRow{ShortURL: "b"}},
+		{line: 3, row: json.DBRow{ShortURL: "a"}},
+		{line: 4, row: json.DBRow{ShortURL: "c"}},
+		{line: 5, row: json.DBRow{ShortURL: "a"}},
+	}
+
+	tests := []struct {
+		name    string
+		skipped []string
+		lines   []int
+	}{
+		{"Nothing skipped", nil, nil},
+		// из повторов кода в пачке сохраняется первый
+		{"Repeats in batch", []string{"a", "a"}, []int{3, 5}},
+		// код уже был в хранилище, поэтому пропущены все вхождения
+		{"Taken code", []string{"a", "a", "a", "c"}, []int{1, 3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []int
+
+			rejectSkipped(batch, tt.skipped, func(p pending) {
+				lines = append(lines, p.line)
+			})
+
+			assert.Equal(t, tt.lines, lines)
+		})
+	}
+}
+
+// run импортирует src в пустое хранилище и возвращает отчёт с отклонёнными строками
+func run(t *testing.T, src string, opts Options) json.ImportReport {
+	var rejects []json.Rejection
+
+	opts.Reject = func(rejection json.Rejection) {
+		rejects = append(rejects, rejection)
+	}
+
+	report, err := Import(context.Background(), strings.NewReader(src), memory.NewStore(), opts)
+	require.NoError(t, err)
+	report.Rejects = rejects
+
+	return report
+}
+
+func TestImportCSV(t *testing.T) {
+	src := "alias,destination\n" +
+		"one,https://import.ru/1\n" +
+		"bad\"quote,https://import.ru/2\n" +
+		"one,https://import.ru/3\n" +
+		"api,https://import.ru/4\n" +
+		"two,not a url\n" +
+		"three,https://import.ru/5\n"
+
+	report := run(t, src, Options{Format: exporter.FormatCSV, Owner: "admin", Reserved: []string{"api"}})
+
+	assert.Equal(t, 6, report.Read)
+	assert.Equal(t, 2, report.Imported)
+	assert.Equal(t, []json.Rejection{
+		{Line: 3, Reason: `bare " in non-quoted-field`},
+		{Line: 5, Alias: "api", Destination: "https://import.ru/4", Reason: "alias is reserved"},
+		{Line: 6, Alias: "two", Destination: "not a url", Reason: "wrong destination"},
+		{Line: 4, Alias: "one", Destination: "https://import.ru/3", Reason: "alias already exists"},
+	}, report.Rejects)
+}
+
+func TestImportJSONL(t *testing.T) {
+	src := `{"alias": "one", "destination": "https://import.ru/1"}` + "\n" +
+		"\n" +
+		`{"alias": "two", "destination": ` + "\n" +
+		`{"alias": "bad/alias", "destination": "https://import.ru/3"}` + "\n" +
+		`{"alias": "four", "destination": "https://import.ru/4"}`
+
+	report := run(t, src, Options{Format: exporter.FormatJSONL, Owner: "admin"})
+
+	assert.Equal(t, 4, report.Read)
+	assert.Equal(t, 2, report.Imported)
+	assert.Equal(t, []json.Rejection{
+		{Line: 3, Alias: "two", Reason: "wrong json"},
+		{Line: 4, Alias: "bad/alias", Destination: "https://import.ru/3", Reason: "wrong alias"},
+	}, report.Rejects)
+}
+
+func TestImportUnknownFormat(t *testing.T) {
+	_, err := Import(context.Background(), strings.NewReader(""), memory.NewStore(), Options{Format: "xml"})
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
@@ -0,0 +1,129 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/mailru/easyjson"
+	"io"
+	"strings"
+)
+
+// csvColumns порядок столбцов CSV без строки заголовка
+var csvColumns = []string{"alias", "destination", "created_at", "owner"}
+
+// csvReader читает CSV. Если первая строка содержит столбец destination, она считается
+// заголовком и задаёт порядок столбцов, иначе используется порядок csvColumns.
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+	started bool
+}
+
+func newCSVReader(src io.Reader) *csvReader {
+	r := csv.NewReader(src)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	r.TrimLeadingSpace = true
+
+	return &csvReader{r: r}
+}
+
+func (c *csvReader) next() (int, json.ImportRow, error) {
+	record, err := c.r.Read()
+
+	var parseErr *csv.ParseError
+
+	if errors.As(err, &parseErr) {
+		return parseErr.StartLine, json.ImportRow{}, rowError{reason: parseErr.Err.Error()}
+	}
+
+	if err != nil {
+		return 0, json.ImportRow{}, err
+	}
+
+	// позиция полей известна только после успешного чтения
+	line, _ := c.r.FieldPos(0)
+
+	if !c.started {
+		c.started = true
+		c.columns = header(record)
+
+		if c.columns != nil {
+			return c.next()
+		}
+
+		c.columns = make(map[string]int, len(csvColumns))
+
+		for i, name := range csvColumns {
+			c.columns[name] = i
+		}
+	}
+
+	field := func(name string) string {
+		if i, ok := c.columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+
+		return ""
+	}
+
+	return line, json.ImportRow{
+		Alias:       field("alias"),
+		Destination: field("destination"),
+		CreatedAt:   field("created_at"),
+		Owner:       field("owner"),
+	}, nil
+}
+
+// header возвращает номера столбцов, если record похож на строку заголовка
+func header(record []string) map[string]int {
+	columns := make(map[string]int, len(record))
+
+	for i, name := range record {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	if _, ok := columns["destination"]; !ok {
+		return nil
+	}
+
+	return columns
+}
+
+// jsonlReader читает по одному JSON объекту из каждой непустой строки
+type jsonlReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func newJSONLReader(src io.Reader) *jsonlReader {
+	return &jsonlReader{r: bufio.NewReader(src)}
+}
+
+func (j *jsonlReader) next() (int, json.ImportRow, error) {
+	for {
+		data, err := j.r.ReadBytes('\n')
+
+		if len(data) == 0 && err != nil {
+			return j.line, json.ImportRow{}, err
+		}
+
+		j.line++
+		data = bytes.TrimSpace(data)
+
+		if len(data) == 0 {
+			continue
+		}
+
+		row := json.ImportRow{}
+
+		if err := easyjson.Unmarshal(data, &row); err != nil {
+			return j.line, row, rowError{reason: "wrong json"}
+		}
+
+		return j.line, row, nil
+	}
+}
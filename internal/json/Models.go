@@ -1,5 +1,7 @@
 package json
 
+import "time"
+
 //easyjson:json
 type Result struct {
 	Result string `json:"result"`
@@ -7,17 +9,111 @@ type Result struct {
 
 //easyjson:json
 type URL struct {
-	URL string `json:"url"`
+	URL          string   `json:"url"`
+	Mode         string   `json:"mode,omitempty"`
+	RedirectType int      `json:"redirect_type,omitempty"`
+	Passthrough  bool     `json:"passthrough,omitempty"`
+	Password     string   `json:"password,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
 }
 
 //easyjson:json
 type DBRow struct {
-	ID            int    `json:"uuid,omitempty"`
-	CorrelationID string `json:"correlation_id,omitempty"`
-	ShortURL      string `json:"short_url,omitempty"`
-	OriginalURL   string `json:"original_url,omitempty"`
-	UserID        string `json:"user_id,omitempty"`
+	ID            int        `json:"uuid,omitempty"`
+	CorrelationID string     `json:"correlation_id,omitempty"`
+	ShortURL      string     `json:"short_url,omitempty"`
+	OriginalURL   string     `json:"original_url,omitempty"`
+	UserID        string     `json:"user_id,omitempty"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	Clicks        int64      `json:"clicks,omitempty"`
+	// Mode режим перехода по ссылке: direct или interstitial, пустой означает режим по умолчанию
+	Mode string `json:"mode,omitempty"`
+	// RedirectType код ответа при переходе: 301, 302, 307 или 308, ноль означает код по умолчанию
+	RedirectType int `json:"redirect_type,omitempty"`
+	// Passthrough переносит в адрес назначения путь после кода и параметры запроса.
+	// Путь qr не переносится: по нему отдаётся QR-код ссылки.
+	Passthrough bool `json:"passthrough,omitempty"`
+	// PasswordHash bcrypt хэш пароля, который нужно ввести перед переходом
+	PasswordHash string `json:"password_hash,omitempty"`
+	// ExpiresAt время, после которого ссылка перестаёт работать
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// UpdatedAt время последнего изменения ссылки владельцем
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+}
+
+// URLPatch изменения ссылки. Незаполненные поля остаются прежними.
+//
+//easyjson:json
+type URLPatch struct {
+	URL          *string `json:"url,omitempty"`
+	RedirectType *int    `json:"redirect_type,omitempty"`
+	// ExpiresAt время в формате RFC 3339, пустая строка снимает ограничение срока
+	ExpiresAt *string   `json:"expires_at,omitempty"`
+	Tags      *[]string `json:"tags,omitempty"`
+}
+
+// URLHistory предыдущая версия ссылки, сохранённая при её изменении
+type URLHistory struct {
+	Version      int        `json:"version"`
+	OriginalURL  string     `json:"original_url"`
+	RedirectType int        `json:"redirect_type,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+	// ChangedAt время, когда версия была заменена следующей
+	ChangedAt *time.Time `json:"changed_at,omitempty"`
+}
+
+//easyjson:json
+type URLHistorySlice []URLHistory
+
+//easyjson:json
+type Rollback struct {
+	Version int `json:"version"`
 }
 
 //easyjson:json
 type BatchURLSlice []DBRow
+
+// Tag тег пользователя и число ссылок с ним
+type Tag struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+//easyjson:json
+type TagSlice []Tag
+
+//easyjson:json
+type TagRename struct {
+	Name string `json:"name"`
+}
+
+// ImportRow ссылка из выгрузки другого сервиса
+//
+//easyjson:json
+type ImportRow struct {
+	Alias       string `json:"alias"`
+	Destination string `json:"destination"`
+	// CreatedAt время создания в формате RFC 3339 или дата YYYY-MM-DD
+	CreatedAt string `json:"created_at"`
+	Owner     string `json:"owner"`
+}
+
+// Rejection строка импорта, которая не была сохранена
+//
+//easyjson:json
+type Rejection struct {
+	Line        int    `json:"line"`
+	Alias       string `json:"alias,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	Reason      string `json:"reason"`
+}
+
+//easyjson:json
+type ImportReport struct {
+	Read     int         `json:"read"`
+	Imported int         `json:"imported"`
+	Rejected int         `json:"rejected"`
+	Rejects  []Rejection `json:"rejects,omitempty"`
+}
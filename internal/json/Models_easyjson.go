@@ -7,6 +7,7 @@ import (
 	easyjson "github.com/mailru/easyjson"
 	jlexer "github.com/mailru/easyjson/jlexer"
 	jwriter "github.com/mailru/easyjson/jwriter"
+	time "time"
 )
 
 // suppress unused package warning
@@ -17,7 +18,367 @@ var (
 	_ easyjson.Marshaler
 )
 
-func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson(in *jlexer.Lexer, out *URL) {
+func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson(in *jlexer.Lexer, out *URLPatch) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "url":
+			if in.IsNull() {
+				in.Skip()
+				out.URL = nil
+			} else {
+				if out.URL == nil {
+					out.URL = new(string)
+				}
+				*out.URL = string(in.String())
+			}
+		case "redirect_type":
+			if in.IsNull() {
+				in.Skip()
+				out.RedirectType = nil
+			} else {
+				if out.RedirectType == nil {
+					out.RedirectType = new(int)
+				}
+				*out.RedirectType = int(in.Int())
+			}
+		case "expires_at":
+			if in.IsNull() {
+				in.Skip()
+				out.ExpiresAt = nil
+			} else {
+				if out.ExpiresAt == nil {
+					out.ExpiresAt = new(string)
+				}
+				*out.ExpiresAt = string(in.String())
+			}
+		case "tags":
+			if in.IsNull() {
+				in.Skip()
+				out.Tags = nil
+			} else {
+				if out.Tags == nil {
+					out.Tags = new([]string)
+				}
+				if in.IsNull() {
+					in.Skip()
+					*out.Tags = nil
+				} else {
+					in.Delim('[')
+					if *out.Tags == nil {
+						if !in.IsDelim(']') {
+							*out.Tags = make([]string, 0, 4)
+						} else {
+							*out.Tags = []string{}
+						}
+					} else {
+						*out.Tags = (*out.Tags)[:0]
+					}
+					for !in.IsDelim(']') {
+						var v1 string
+						v1 = string(in.String())
+						*out.Tags = append(*out.Tags, v1)
+						in.WantComma()
+					}
+					in.Delim(']')
+				}
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson(out *jwriter.Writer, in URLPatch) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	if in.URL != nil {
+		const prefix string = ",\"url\":"
+		first = false
+		out.RawString(prefix[1:])
+		out.String(string(*in.URL))
+	}
+	if in.RedirectType != nil {
+		const prefix string = ",\"redirect_type\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.Int(int(*in.RedirectType))
+	}
+	if in.ExpiresAt != nil {
+		const prefix string = ",\"expires_at\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(*in.ExpiresAt))
+	}
+	if in.Tags != nil {
+		const prefix string = ",\"tags\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		if *in.Tags == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v2, v3 := range *in.Tags {
+				if v2 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v3))
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v URLPatch) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v URLPatch) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *URLPatch) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *URLPatch) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson(l, v)
+}
+func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson1(in *jlexer.Lexer, out *URLHistorySlice) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(URLHistorySlice, 0, 0)
+			} else {
+				*out = URLHistorySlice{}
+			}
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v4 URLHistory
+			easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson2(in, &v4)
+			*out = append(*out, v4)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson1(out *jwriter.Writer, in URLHistorySlice) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v5, v6 := range in {
+			if v5 > 0 {
+				out.RawByte(',')
+			}
+			easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson2(out, v6)
+		}
+		out.RawByte(']')
+	}
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v URLHistorySlice) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v URLHistorySlice) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *URLHistorySlice) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *URLHistorySlice) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson1(l, v)
+}
+func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson2(in *jlexer.Lexer, out *URLHistory) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "version":
+			out.Version = int(in.Int())
+		case "original_url":
+			out.OriginalURL = string(in.String())
+		case "redirect_type":
+			out.RedirectType = int(in.Int())
+		case "expires_at":
+			if in.IsNull() {
+				in.Skip()
+				out.ExpiresAt = nil
+			} else {
+				if out.ExpiresAt == nil {
+					out.ExpiresAt = new(time.Time)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError((*out.ExpiresAt).UnmarshalJSON(data))
+				}
+			}
+		case "tags":
+			if in.IsNull() {
+				in.Skip()
+				out.Tags = nil
+			} else {
+				in.Delim('[')
+				if out.Tags == nil {
+					if !in.IsDelim(']') {
+						out.Tags = make([]string, 0, 4)
+					} else {
+						out.Tags = []string{}
+					}
+				} else {
+					out.Tags = (out.Tags)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v7 string
+					v7 = string(in.String())
+					out.Tags = append(out.Tags, v7)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "changed_at":
+			if in.IsNull() {
+				in.Skip()
+				out.ChangedAt = nil
+			} else {
+				if out.ChangedAt == nil {
+					out.ChangedAt = new(time.Time)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError((*out.ChangedAt).UnmarshalJSON(data))
+				}
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson2(out *jwriter.Writer, in URLHistory) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"version\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.Version))
+	}
+	{
+		const prefix string = ",\"original_url\":"
+		out.RawString(prefix)
+		out.String(string(in.OriginalURL))
+	}
+	if in.RedirectType != 0 {
+		const prefix string = ",\"redirect_type\":"
+		out.RawString(prefix)
+		out.Int(int(in.RedirectType))
+	}
+	if in.ExpiresAt != nil {
+		const prefix string = ",\"expires_at\":"
+		out.RawString(prefix)
+		out.Raw((*in.ExpiresAt).MarshalJSON())
+	}
+	if len(in.Tags) != 0 {
+		const prefix string = ",\"tags\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v8, v9 := range in.Tags {
+				if v8 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v9))
+			}
+			out.RawByte(']')
+		}
+	}
+	if in.ChangedAt != nil {
+		const prefix string = ",\"changed_at\":"
+		out.RawString(prefix)
+		out.Raw((*in.ChangedAt).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson3(in *jlexer.Lexer, out *URL) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -38,6 +399,384 @@ func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson(in *jlexer.Lexer
 		switch key {
 		case "url":
 			out.URL = string(in.String())
+		case "mode":
+			out.Mode = string(in.String())
+		case "redirect_type":
+			out.RedirectType = int(in.Int())
+		case "passthrough":
+			out.Passthrough = bool(in.Bool())
+		case "password":
+			out.Password = string(in.String())
+		case "tags":
+			if in.IsNull() {
+				in.Skip()
+				out.Tags = nil
+			} else {
+				in.Delim('[')
+				if out.Tags == nil {
+					if !in.IsDelim(']') {
+						out.Tags = make([]string, 0, 4)
+					} else {
+						out.Tags = []string{}
+					}
+				} else {
+					out.Tags = (out.Tags)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v10 string
+					v10 = string(in.String())
+					out.Tags = append(out.Tags, v10)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson3(out *jwriter.Writer, in URL) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"url\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.URL))
+	}
+	if in.Mode != "" {
+		const prefix string = ",\"mode\":"
+		out.RawString(prefix)
+		out.String(string(in.Mode))
+	}
+	if in.RedirectType != 0 {
+		const prefix string = ",\"redirect_type\":"
+		out.RawString(prefix)
+		out.Int(int(in.RedirectType))
+	}
+	if in.Passthrough {
+		const prefix string = ",\"passthrough\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.Passthrough))
+	}
+	if in.Password != "" {
+		const prefix string = ",\"password\":"
+		out.RawString(prefix)
+		out.String(string(in.Password))
+	}
+	if len(in.Tags) != 0 {
+		const prefix string = ",\"tags\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v11, v12 := range in.Tags {
+				if v11 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v12))
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v URL) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v URL) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *URL) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *URL) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson3(l, v)
+}
+func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson4(in *jlexer.Lexer, out *TagSlice) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(TagSlice, 0, 2)
+			} else {
+				*out = TagSlice{}
+			}
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v13 Tag
+			easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson5(in, &v13)
+			*out = append(*out, v13)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson4(out *jwriter.Writer, in TagSlice) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v14, v15 := range in {
+			if v14 > 0 {
+				out.RawByte(',')
+			}
+			easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson5(out, v15)
+		}
+		out.RawByte(']')
+	}
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v TagSlice) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson4(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v TagSlice) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson4(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *TagSlice) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson4(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *TagSlice) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson4(l, v)
+}
+func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson5(in *jlexer.Lexer, out *Tag) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "name":
+			out.Name = string(in.String())
+		case "count":
+			out.Count = int(in.Int())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson5(out *jwriter.Writer, in Tag) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"name\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Name))
+	}
+	{
+		const prefix string = ",\"count\":"
+		out.RawString(prefix)
+		out.Int(int(in.Count))
+	}
+	out.RawByte('}')
+}
+func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson6(in *jlexer.Lexer, out *TagRename) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "name":
+			out.Name = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson6(out *jwriter.Writer, in TagRename) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"name\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Name))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v TagRename) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson6(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v TagRename) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson6(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *TagRename) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson6(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *TagRename) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson6(l, v)
+}
+func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson7(in *jlexer.Lexer, out *Rollback) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "version":
+			out.Version = int(in.Int())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson7(out *jwriter.Writer, in Rollback) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"version\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.Version))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Rollback) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson7(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Rollback) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson7(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Rollback) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson7(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Rollback) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson7(l, v)
+}
+func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson8(in *jlexer.Lexer, out *Result) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "result":
+			out.Result = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -48,42 +787,42 @@ func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson(in *jlexer.Lexer
 		in.Consumed()
 	}
 }
-func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson(out *jwriter.Writer, in URL) {
+func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson8(out *jwriter.Writer, in Result) {
 	out.RawByte('{')
 	first := true
 	_ = first
 	{
-		const prefix string = ",\"url\":"
+		const prefix string = ",\"result\":"
 		out.RawString(prefix[1:])
-		out.String(string(in.URL))
+		out.String(string(in.Result))
 	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
-func (v URL) MarshalJSON() ([]byte, error) {
+func (v Result) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson(&w, v)
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson8(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
-func (v URL) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson(w, v)
+func (v Result) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson8(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
-func (v *URL) UnmarshalJSON(data []byte) error {
+func (v *Result) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson(&r, v)
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson8(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
-func (v *URL) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson(l, v)
+func (v *Result) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson8(l, v)
 }
-func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson1(in *jlexer.Lexer, out *Result) {
+func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson9(in *jlexer.Lexer, out *Rejection) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -102,8 +841,14 @@ func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson1(in *jlexer.Lexe
 			continue
 		}
 		switch key {
-		case "result":
-			out.Result = string(in.String())
+		case "line":
+			out.Line = int(in.Int())
+		case "alias":
+			out.Alias = string(in.String())
+		case "destination":
+			out.Destination = string(in.String())
+		case "reason":
+			out.Reason = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -114,42 +859,261 @@ func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson1(in *jlexer.Lexe
 		in.Consumed()
 	}
 }
-func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson1(out *jwriter.Writer, in Result) {
+func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson9(out *jwriter.Writer, in Rejection) {
 	out.RawByte('{')
 	first := true
 	_ = first
 	{
-		const prefix string = ",\"result\":"
+		const prefix string = ",\"line\":"
 		out.RawString(prefix[1:])
-		out.String(string(in.Result))
+		out.Int(int(in.Line))
+	}
+	if in.Alias != "" {
+		const prefix string = ",\"alias\":"
+		out.RawString(prefix)
+		out.String(string(in.Alias))
+	}
+	if in.Destination != "" {
+		const prefix string = ",\"destination\":"
+		out.RawString(prefix)
+		out.String(string(in.Destination))
+	}
+	{
+		const prefix string = ",\"reason\":"
+		out.RawString(prefix)
+		out.String(string(in.Reason))
 	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
-func (v Result) MarshalJSON() ([]byte, error) {
+func (v Rejection) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson1(&w, v)
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson9(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
-func (v Result) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson1(w, v)
+func (v Rejection) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson9(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
-func (v *Result) UnmarshalJSON(data []byte) error {
+func (v *Rejection) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson1(&r, v)
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson9(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
-func (v *Result) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson1(l, v)
+func (v *Rejection) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson9(l, v)
+}
+func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson10(in *jlexer.Lexer, out *ImportRow) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "alias":
+			out.Alias = string(in.String())
+		case "destination":
+			out.Destination = string(in.String())
+		case "created_at":
+			out.CreatedAt = string(in.String())
+		case "owner":
+			out.Owner = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson10(out *jwriter.Writer, in ImportRow) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"alias\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Alias))
+	}
+	{
+		const prefix string = ",\"destination\":"
+		out.RawString(prefix)
+		out.String(string(in.Destination))
+	}
+	{
+		const prefix string = ",\"created_at\":"
+		out.RawString(prefix)
+		out.String(string(in.CreatedAt))
+	}
+	{
+		const prefix string = ",\"owner\":"
+		out.RawString(prefix)
+		out.String(string(in.Owner))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ImportRow) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson10(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ImportRow) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson10(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ImportRow) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson10(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ImportRow) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson10(l, v)
+}
+func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson11(in *jlexer.Lexer, out *ImportReport) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "read":
+			out.Read = int(in.Int())
+		case "imported":
+			out.Imported = int(in.Int())
+		case "rejected":
+			out.Rejected = int(in.Int())
+		case "rejects":
+			if in.IsNull() {
+				in.Skip()
+				out.Rejects = nil
+			} else {
+				in.Delim('[')
+				if out.Rejects == nil {
+					if !in.IsDelim(']') {
+						out.Rejects = make([]Rejection, 0, 1)
+					} else {
+						out.Rejects = []Rejection{}
+					}
+				} else {
+					out.Rejects = (out.Rejects)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v16 Rejection
+					(v16).UnmarshalEasyJSON(in)
+					out.Rejects = append(out.Rejects, v16)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson11(out *jwriter.Writer, in ImportReport) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"read\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.Read))
+	}
+	{
+		const prefix string = ",\"imported\":"
+		out.RawString(prefix)
+		out.Int(int(in.Imported))
+	}
+	{
+		const prefix string = ",\"rejected\":"
+		out.RawString(prefix)
+		out.Int(int(in.Rejected))
+	}
+	if len(in.Rejects) != 0 {
+		const prefix string = ",\"rejects\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v17, v18 := range in.Rejects {
+				if v17 > 0 {
+					out.RawByte(',')
+				}
+				(v18).MarshalEasyJSON(out)
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ImportReport) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson11(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ImportReport) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson11(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ImportReport) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson11(&r, v)
+	return r.Error()
 }
-func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson2(in *jlexer.Lexer, out *DBRow) {
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ImportReport) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson11(l, v)
+}
+func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson12(in *jlexer.Lexer, out *DBRow) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -178,6 +1142,75 @@ func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson2(in *jlexer.Lexe
 			out.OriginalURL = string(in.String())
 		case "user_id":
 			out.UserID = string(in.String())
+		case "created_at":
+			if in.IsNull() {
+				in.Skip()
+				out.CreatedAt = nil
+			} else {
+				if out.CreatedAt == nil {
+					out.CreatedAt = new(time.Time)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError((*out.CreatedAt).UnmarshalJSON(data))
+				}
+			}
+		case "clicks":
+			out.Clicks = int64(in.Int64())
+		case "mode":
+			out.Mode = string(in.String())
+		case "redirect_type":
+			out.RedirectType = int(in.Int())
+		case "passthrough":
+			out.Passthrough = bool(in.Bool())
+		case "password_hash":
+			out.PasswordHash = string(in.String())
+		case "expires_at":
+			if in.IsNull() {
+				in.Skip()
+				out.ExpiresAt = nil
+			} else {
+				if out.ExpiresAt == nil {
+					out.ExpiresAt = new(time.Time)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError((*out.ExpiresAt).UnmarshalJSON(data))
+				}
+			}
+		case "updated_at":
+			if in.IsNull() {
+				in.Skip()
+				out.UpdatedAt = nil
+			} else {
+				if out.UpdatedAt == nil {
+					out.UpdatedAt = new(time.Time)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError((*out.UpdatedAt).UnmarshalJSON(data))
+				}
+			}
+		case "tags":
+			if in.IsNull() {
+				in.Skip()
+				out.Tags = nil
+			} else {
+				in.Delim('[')
+				if out.Tags == nil {
+					if !in.IsDelim(']') {
+						out.Tags = make([]string, 0, 4)
+					} else {
+						out.Tags = []string{}
+					}
+				} else {
+					out.Tags = (out.Tags)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v19 string
+					v19 = string(in.String())
+					out.Tags = append(out.Tags, v19)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -188,7 +1221,7 @@ func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson2(in *jlexer.Lexe
 		in.Consumed()
 	}
 }
-func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson2(out *jwriter.Writer, in DBRow) {
+func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson12(out *jwriter.Writer, in DBRow) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -238,33 +1271,132 @@ func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson2(out *jwriter.Wr
 		}
 		out.String(string(in.UserID))
 	}
+	if in.CreatedAt != nil {
+		const prefix string = ",\"created_at\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.Raw((*in.CreatedAt).MarshalJSON())
+	}
+	if in.Clicks != 0 {
+		const prefix string = ",\"clicks\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.Int64(int64(in.Clicks))
+	}
+	if in.Mode != "" {
+		const prefix string = ",\"mode\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.Mode))
+	}
+	if in.RedirectType != 0 {
+		const prefix string = ",\"redirect_type\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.Int(int(in.RedirectType))
+	}
+	if in.Passthrough {
+		const prefix string = ",\"passthrough\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.Bool(bool(in.Passthrough))
+	}
+	if in.PasswordHash != "" {
+		const prefix string = ",\"password_hash\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.PasswordHash))
+	}
+	if in.ExpiresAt != nil {
+		const prefix string = ",\"expires_at\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.Raw((*in.ExpiresAt).MarshalJSON())
+	}
+	if in.UpdatedAt != nil {
+		const prefix string = ",\"updated_at\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.Raw((*in.UpdatedAt).MarshalJSON())
+	}
+	if len(in.Tags) != 0 {
+		const prefix string = ",\"tags\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		{
+			out.RawByte('[')
+			for v20, v21 := range in.Tags {
+				if v20 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v21))
+			}
+			out.RawByte(']')
+		}
+	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
 func (v DBRow) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson2(&w, v)
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson12(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v DBRow) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson2(w, v)
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson12(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *DBRow) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson2(&r, v)
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson12(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *DBRow) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson2(l, v)
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson12(l, v)
 }
-func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson3(in *jlexer.Lexer, out *BatchURLSlice) {
+func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson13(in *jlexer.Lexer, out *BatchURLSlice) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		in.Skip()
@@ -281,9 +1413,9 @@ func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson3(in *jlexer.Lexe
 			*out = (*out)[:0]
 		}
 		for !in.IsDelim(']') {
-			var v1 DBRow
-			(v1).UnmarshalEasyJSON(in)
-			*out = append(*out, v1)
+			var v22 DBRow
+			(v22).UnmarshalEasyJSON(in)
+			*out = append(*out, v22)
 			in.WantComma()
 		}
 		in.Delim(']')
@@ -292,16 +1424,16 @@ func easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson3(in *jlexer.Lexe
 		in.Consumed()
 	}
 }
-func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson3(out *jwriter.Writer, in BatchURLSlice) {
+func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson13(out *jwriter.Writer, in BatchURLSlice) {
 	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
 		out.RawString("null")
 	} else {
 		out.RawByte('[')
-		for v2, v3 := range in {
-			if v2 > 0 {
+		for v23, v24 := range in {
+			if v23 > 0 {
 				out.RawByte(',')
 			}
-			(v3).MarshalEasyJSON(out)
+			(v24).MarshalEasyJSON(out)
 		}
 		out.RawByte(']')
 	}
@@ -310,23 +1442,23 @@ func easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson3(out *jwriter.Wr
 // MarshalJSON supports json.Marshaler interface
 func (v BatchURLSlice) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson3(&w, v)
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson13(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v BatchURLSlice) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson3(w, v)
+	easyjsonD2ecc9deEncodeGithubComLaikerShortenerInternalJson13(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *BatchURLSlice) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson3(&r, v)
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson13(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *BatchURLSlice) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson3(l, v)
+	easyjsonD2ecc9deDecodeGithubComLaikerShortenerInternalJson13(l, v)
 }
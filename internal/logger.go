@@ -1,13 +1,30 @@
 package logger
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/laiker/shortener/cmd/config"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"net/http"
+	"strconv"
 	"time"
 )
 
+// RequestIDHeader заголовок, в котором передаётся и возвращается идентификатор запроса
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает длину идентификатора, пришедшего от клиента
+const maxRequestIDLength = 128
+
 var Log *zap.Logger = zap.NewNop()
 
+// AccessLog пишет журнал входящих запросов, при необходимости с семплированием
+var AccessLog *zap.Logger = zap.NewNop()
+
+var accessLevel = zapcore.InfoLevel
+
 type (
 	responseData struct {
 		status int
@@ -48,16 +65,92 @@ func Initialize(level string) error {
 	}
 
 	Log = zl
+	AccessLog = zl
 
 	return nil
 }
 
+// InitializeAccessLog настраивает журнал запросов поверх Log: уровень записей и семплирование.
+// Каждую секунду пишутся первые first записей, а затем только каждая thereafter-я.
+// При thereafter <= 0 семплирование отключено.
+func InitializeAccessLog(level string, first, thereafter int) error {
+	lvl, err := zapcore.ParseLevel(level)
+
+	if err != nil {
+		return err
+	}
+
+	core := Log.Core()
+
+	if thereafter > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, first, thereafter)
+	}
+
+	AccessLog = zap.New(core)
+	accessLevel = lvl
+
+	return nil
+}
+
+// RequestID возвращает идентификатор запроса из контекста
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(config.RequestIDKey).(string)
+	return requestID
+}
+
+// FromContext возвращает логгер, к записям которого добавлен идентификатор запроса из контекста
+func FromContext(ctx context.Context) *zap.Logger {
+	if requestID := RequestID(ctx); requestID != "" {
+		return Log.With(zap.String("request_id", requestID))
+	}
+
+	return Log
+}
+
+func generateRequestID() string {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// validRequestID пропускает только короткие идентификаторы из печатных ASCII символов,
+// чтобы клиент не мог испортить журнал
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
 func RequestLogger(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		time := time.Now()
+		start := time.Now()
+
+		requestID := r.Header.Get(RequestIDHeader)
+
+		if !validRequestID(requestID) {
+			requestID = generateRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := context.WithValue(r.Context(), config.RequestIDKey, requestID)
+		r = r.WithContext(ctx)
 
-		responseData := &responseData{}
+		// если обработчик не вызвал WriteHeader, net/http отвечает 200
+		responseData := &responseData{status: http.StatusOK}
 		writer := &loggingResponseWriter{
 			ResponseWriter: w,
 			responseData:   responseData,
@@ -65,13 +158,15 @@ func RequestLogger(h http.Handler) http.Handler {
 
 		h.ServeHTTP(writer, r)
 
-		Log.Debug("got incoming HTTP request",
-			zap.String("method", r.Method),
-			zap.String("path", r.URL.Path),
-			zap.String("time duration: ", time.Sub(time).String()),
-			zap.Int("status", responseData.status),
-			zap.Int("content length", responseData.size),
-		)
-
+		if ce := AccessLog.Check(accessLevel, "got incoming HTTP request"); ce != nil {
+			ce.Write(
+				zap.String("request_id", requestID),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Duration("duration", time.Since(start)),
+				zap.Int("status", responseData.status),
+				zap.Int("content length", responseData.size),
+			)
+		}
 	})
 }
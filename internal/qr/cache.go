@@ -0,0 +1,70 @@
+package qr
+
+import (
+	"container/list"
+	"sync"
+)
+
+type cacheEntry struct {
+	key   string
+	image []byte
+}
+
+// Cache хранит последние построенные изображения, вытесняя давно не запрошенные
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// NewCache возвращает кэш на size изображений
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Encode возвращает изображение из кэша или строит и запоминает его
+func (c *Cache) Encode(content string, opts Options) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	key := opts.key(content)
+
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		c.mu.Unlock()
+		return element.Value.(*cacheEntry).image, nil
+	}
+	c.mu.Unlock()
+
+	image, err := Encode(content, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size <= 0 {
+		return image, nil
+	}
+
+	if _, ok := c.entries[key]; !ok {
+		c.entries[key] = c.order.PushFront(&cacheEntry{key: key, image: image})
+	}
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+
+	return image, nil
+}
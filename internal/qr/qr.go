@@ -0,0 +1,171 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/skip2/go-qrcode"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// Форматы изображения
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+const (
+	MinSize   = 64
+	MaxSize   = 2048
+	MaxMargin = 16
+)
+
+var ErrInvalidOptions = errors.New("invalid qr code options")
+
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// Options параметры изображения QR кода
+type Options struct {
+	Format string
+	// Size ширина и высота изображения в пикселях
+	Size int
+	// Level уровень коррекции ошибок: L, M, Q или H
+	Level string
+	// Margin ширина светлой рамки в модулях
+	Margin int
+}
+
+// DefaultOptions параметры, подходящие для печати и экрана
+func DefaultOptions() Options {
+	return Options{
+		Format: FormatPNG,
+		Size:   256,
+		Level:  "M",
+		Margin: 4,
+	}
+}
+
+// Validate проверяет параметры и приводит формат и уровень к нижнему и верхнему регистру
+func (o *Options) Validate() error {
+	o.Format = strings.ToLower(o.Format)
+	o.Level = strings.ToUpper(o.Level)
+
+	if o.Format != FormatPNG && o.Format != FormatSVG {
+		return fmt.Errorf("%w: format %q", ErrInvalidOptions, o.Format)
+	}
+
+	if o.Size < MinSize || o.Size > MaxSize {
+		return fmt.Errorf("%w: size must be between %d and %d", ErrInvalidOptions, MinSize, MaxSize)
+	}
+
+	if _, ok := levels[o.Level]; !ok {
+		return fmt.Errorf("%w: level %q", ErrInvalidOptions, o.Level)
+	}
+
+	if o.Margin < 0 || o.Margin > MaxMargin {
+		return fmt.Errorf("%w: margin must be between 0 and %d", ErrInvalidOptions, MaxMargin)
+	}
+
+	return nil
+}
+
+// ContentType возвращает MIME тип изображения
+func (o Options) ContentType() string {
+	if o.Format == FormatSVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+func (o Options) key(content string) string {
+	return fmt.Sprintf("%s|%d|%s|%d|%s", o.Format, o.Size, o.Level, o.Margin, content)
+}
+
+// Encode строит QR код для content и возвращает изображение в формате из opts
+func Encode(content string, opts Options) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	code, err := qrcode.New(content, levels[opts.Level])
+
+	if err != nil {
+		return nil, err
+	}
+
+	// рамку рисуем сами, чтобы ширина задавалась параметром
+	code.DisableBorder = true
+	modules := code.Bitmap()
+
+	if opts.Format == FormatSVG {
+		return renderSVG(modules, opts), nil
+	}
+
+	return renderPNG(modules, opts)
+}
+
+func renderPNG(modules [][]bool, opts Options) ([]byte, error) {
+	total := len(modules) + 2*opts.Margin
+	scale := opts.Size / total
+
+	if scale < 1 {
+		return nil, fmt.Errorf("%w: size is too small for the content", ErrInvalidOptions)
+	}
+
+	// модули одного размера, остаток размера делим поровну по краям
+	offset := (opts.Size-scale*total)/2 + opts.Margin*scale
+
+	img := image.NewPaletted(image.Rect(0, 0, opts.Size, opts.Size), color.Palette{color.White, color.Black})
+
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(offset+x*scale+dx, offset+y*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func renderSVG(modules [][]bool, opts Options) []byte {
+	total := len(modules) + 2*opts.Margin
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, total, total)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, total, total)
+
+	for y, row := range modules {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x+opts.Margin, y+opts.Margin)
+			}
+		}
+	}
+
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// cleanupEvery через сколько вызовов Allow удаляются полностью восстановившиеся корзины
+const cleanupEvery = 1024
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rule   Rule
+}
+
+// MemoryLimiter хранит корзины в памяти процесса
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+	now     func() time.Time
+}
+
+// NewMemoryLimiter возвращает новый экземпляр ограничителя в памяти
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	key = rule.Name + ":" + key
+
+	b, ok := l.buckets[key]
+
+	if !ok {
+		b = &bucket{tokens: float64(rule.Burst), last: now, rule: rule}
+		l.buckets[key] = b
+	}
+
+	var result Result
+	b.tokens, result = take(b.tokens, b.last, now, rule)
+	b.last = now
+	b.rule = rule
+
+	l.calls++
+	if l.calls%cleanupEvery == 0 {
+		l.cleanup(now)
+	}
+
+	return result, nil
+}
+
+func (l *MemoryLimiter) Refund(ctx context.Context, key string, rule Rule) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[rule.Name+":"+key]; ok {
+		b.tokens = math.Min(float64(rule.Burst), b.tokens+1)
+	}
+
+	return nil
+}
+
+// cleanup удаляет корзины, которые успели бы наполниться полностью: они не отличаются от новых
+func (l *MemoryLimiter) cleanup(now time.Time) {
+	for key, b := range l.buckets {
+		full := seconds((float64(b.rule.Burst) - b.tokens) / b.rule.Rate)
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
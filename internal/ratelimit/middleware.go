@@ -0,0 +1,183 @@
+package ratelimit
+
+import (
+	"github.com/laiker/shortener/cmd/config"
+	logger "github.com/laiker/shortener/internal"
+	"go.uber.org/zap"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyFunc возвращает ключи, по каждому из которых запрос должен уложиться в лимит
+type KeyFunc func(r *http.Request) []string
+
+// Middleware ограничивает частоту запросов по правилу rule для всех ключей из keys.
+// Выставляет заголовки RateLimit-*, а при превышении лимита отвечает 429 с Retry-After.
+// Ключи проверяются по порядку до первого отказа, токены следующих ключей не тратятся.
+// Если хранилище лимитов недоступно, запрос пропускается.
+func Middleware(l Limiter, rule Rule, keys KeyFunc) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		if !rule.Enabled() {
+			return h
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var strictest *Result
+
+			for _, key := range keys(r) {
+				result, err := l.Allow(r.Context(), key, rule)
+
+				if err != nil {
+					logger.FromContext(r.Context()).Info("rate limiter failed", zap.Error(err))
+					continue
+				}
+
+				if strictest == nil || stricter(result, *strictest) {
+					strictest = &result
+				}
+
+				if !result.Allowed {
+					break
+				}
+			}
+
+			if strictest == nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(strictest.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(strictest.Remaining))
+			w.Header().Set("RateLimit-Reset", ceilSeconds(strictest.Reset))
+
+			if !strictest.Allowed {
+				w.Header().Set("Retry-After", ceilSeconds(strictest.RetryAfter))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// stricter сообщает, что результат a ограничивает клиента сильнее, чем b
+func stricter(a, b Result) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+
+	return a.Remaining < b.Remaining
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// UserAndIPKeys возвращает KeyFunc, который ограничивает запросы и по пользователю из контекста,
+// и по IP адресу клиента. Должен работать после userMiddleware. Пользователь, ID которого создан
+// для этого запроса, ограничивается только по IP: иначе каждый запрос без токена заводил бы новый счётчик.
+func UserAndIPKeys(trusted []*net.IPNet) KeyFunc {
+	return func(r *http.Request) []string {
+		keys := []string{"ip:" + ClientIP(r, trusted)}
+
+		if generated, _ := r.Context().Value(config.UserGeneratedKey).(bool); generated {
+			return keys
+		}
+
+		if userID, _ := r.Context().Value(config.UserIDKey).(string); userID != "" {
+			keys = append(keys, "user:"+userID)
+		}
+
+		return keys
+	}
+}
+
+// ClientIP определяет адрес клиента. Заголовкам X-Forwarded-For и X-Real-IP доверяем,
+// только если запрос пришёл от доверенного прокси.
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+
+	if !isTrusted(net.ParseIP(remote), trusted) {
+		return remote
+	}
+
+	// идём справа налево: правые адреса добавлены нашими прокси, левые мог подставить клиент
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			ip := net.ParseIP(hop)
+
+			if ip == nil {
+				break
+			}
+
+			if !isTrusted(ip, trusted) {
+				return ip.String()
+			}
+		}
+	}
+
+	if realIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIP != nil {
+		return realIP.String()
+	}
+
+	return remote
+}
+
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseTrustedProxies разбирает список подсетей или адресов через запятую
+func ParseTrustedProxies(list string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+
+		if item == "" {
+			continue
+		}
+
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
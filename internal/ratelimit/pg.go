@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	logger "github.com/laiker/shortener/internal"
+	"go.uber.org/zap"
+	"sync/atomic"
+	"time"
+)
+
+// PgLimiter хранит корзины в PostgreSQL, чтобы лимиты были общими для всех экземпляров сервиса
+type PgLimiter struct {
+	conn  *pgxpool.Pool
+	calls atomic.Int64
+}
+
+// NewPgLimiter возвращает ограничитель поверх пула соединений с PostgreSQL
+func NewPgLimiter(conn *pgxpool.Pool) *PgLimiter {
+	return &PgLimiter{conn: conn}
+}
+
+// Bootstrap создаёт таблицу счётчиков
+func (l *PgLimiter) Bootstrap(ctx context.Context) error {
+	_, err := l.conn.Exec(ctx, `
+			CREATE TABLE IF NOT EXISTS rate_limits (
+				key varchar PRIMARY KEY,
+				tokens double precision NOT NULL,
+				updated_at timestamptz NOT NULL
+			);
+			ALTER TABLE rate_limits ADD COLUMN IF NOT EXISTS full_at timestamptz NOT NULL DEFAULT now();
+			CREATE INDEX IF NOT EXISTS rate_limits_full_at_idx ON rate_limits (full_at)
+	    `)
+
+	return err
+}
+
+func (l *PgLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	key = rule.Name + ":" + key
+
+	tx, err := l.conn.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Result{}, err
+	}
+
+	// в случае неуспешного коммита все изменения транзакции будут отменены
+	defer tx.Rollback(ctx)
+
+	// время берём из БД, чтобы расхождение часов экземпляров не влияло на пополнение.
+	// Пустое обновление при конфликте блокирует строку и возвращает её как есть
+	var tokens float64
+	var last, now time.Time
+
+	err = tx.QueryRow(ctx, `
+			INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, $2, now())
+			ON CONFLICT (key) DO UPDATE SET key = EXCLUDED.key
+			RETURNING tokens, updated_at, now()
+		`, key, float64(rule.Burst)).Scan(&tokens, &last, &now)
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	tokens, result := take(tokens, last, now, rule)
+
+	_, err = tx.Exec(ctx, "UPDATE rate_limits SET tokens = $2, updated_at = $3, full_at = $4 WHERE key = $1",
+		key, tokens, now, now.Add(result.Reset))
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Result{}, err
+	}
+
+	if l.calls.Add(1)%cleanupEvery == 0 {
+		l.cleanup(ctx)
+	}
+
+	return result, nil
+}
+
+// cleanup удаляет корзины, которые успели наполниться полностью: они не отличаются от новых.
+// Ошибка только записывается в лог: лимит уже посчитан, а корзины удалит следующая очистка
+func (l *PgLimiter) cleanup(ctx context.Context) {
+	if _, err := l.conn.Exec(ctx, "DELETE FROM rate_limits WHERE full_at <= now()"); err != nil {
+		logger.FromContext(ctx).Info("Rate limit cleanup failed", zap.Error(err))
+	}
+}
+
+func (l *PgLimiter) Refund(ctx context.Context, key string, rule Rule) error {
+	_, err := l.conn.Exec(ctx, "UPDATE rate_limits SET tokens = LEAST(tokens + 1, $2) WHERE key = $1",
+		rule.Name+":"+key, float64(rule.Burst))
+
+	return err
+}
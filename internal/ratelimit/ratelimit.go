@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Rule описывает token bucket: ёмкость Burst токенов, которая пополняется со скоростью Rate токенов в секунду
+type Rule struct {
+	// Name отделяет счётчики разных групп маршрутов друг от друга
+	Name  string
+	Rate  float64
+	Burst int
+}
+
+// Enabled сообщает, включено ли ограничение
+func (r Rule) Enabled() bool {
+	return r.Rate > 0 && r.Burst > 0
+}
+
+// Result результат попытки взять токен из корзины
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter через сколько появится следующий токен, если запрос отклонён
+	RetryAfter time.Duration
+	// Reset через сколько корзина наполнится полностью
+	Reset time.Duration
+}
+
+// Limiter хранит корзины токенов по ключам
+type Limiter interface {
+	// Allow берёт токен из корзины key
+	Allow(ctx context.Context, key string, rule Rule) (Result, error)
+	// Refund возвращает в корзину key токен, взятый Allow
+	Refund(ctx context.Context, key string, rule Rule) error
+}
+
+// take пополняет корзину за время, прошедшее с last, и пытается взять из неё один токен.
+// Возвращает новое число токенов и результат попытки.
+func take(tokens float64, last, now time.Time, rule Rule) (float64, Result) {
+	burst := float64(rule.Burst)
+
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(burst, tokens+elapsed*rule.Rate)
+	}
+
+	result := Result{Limit: rule.Burst}
+
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - tokens) / rule.Rate)
+	}
+
+	result.Remaining = int(math.Floor(tokens))
+	result.Reset = seconds((burst - tokens) / rule.Rate)
+
+	return tokens, result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	logger "github.com/laiker/shortener/internal"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"github.com/mailru/easyjson"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Backend хранит закэшированные значения по ключу
+type Backend interface {
+	// Get возвращает значение key, ok равен false, если его нет или истёк срок жизни
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Pinger реализуют кэши, доступность которых можно проверить
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Options время жизни записей кэша
+type Options struct {
+	// TTL время жизни найденной ссылки, 0 отключает кэширование
+	TTL time.Duration
+	// NegativeTTL время жизни отметки о несуществующем коде, 0 отключает такие отметки
+	NegativeTTL time.Duration
+}
+
+// Store кэширует ответы GetURL хранилища в backends. Кэши опрашиваются по порядку, найденное
+// в дальнем кэше копируется в ближние. Изменения ссылок через Store удаляют их из всех кэшей,
+// но счётчик переходов в закэшированной ссылке обновляется только по истечении TTL.
+//
+// Остальные методы передаются хранилищу как есть, поэтому методы, меняющие ссылки,
+// нужно переопределять здесь.
+type Store struct {
+	store.Store
+	backends []Backend
+	opts     Options
+}
+
+// NewStore возвращает хранилище s с кэшем ссылок в backends
+func NewStore(s store.Store, opts Options, backends ...Backend) *Store {
+	return &Store{Store: s, backends: backends, opts: opts}
+}
+
+// Ping проверяет кэши, которые реализуют Pinger, например, Redis
+func (s *Store) Ping(ctx context.Context) error {
+	for _, backend := range s.backends {
+		pinger, ok := backend.(Pinger)
+
+		if !ok {
+			continue
+		}
+
+		if err := pinger.Ping(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Unwrap возвращает хранилище за кэшем
+func (s *Store) Unwrap() store.Store {
+	return s.Store
+}
+
+func (s *Store) GetURL(ctx context.Context, short string) (json.DBRow, error) {
+	for i, backend := range s.backends {
+		value, ok, err := backend.Get(ctx, short)
+
+		if err != nil {
+			// недоступный кэш не должен мешать переходам, идём дальше к хранилищу
+			logger.FromContext(ctx).Info("Cache get failed", zap.Error(err))
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		s.set(ctx, s.backends[:i], short, value)
+
+		return decode(value)
+	}
+
+	row, err := s.Store.GetURL(ctx, short)
+
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		s.set(ctx, s.backends, short, nil)
+	case err == nil:
+		if value, err := easyjson.Marshal(row); err == nil {
+			s.set(ctx, s.backends, short, value)
+		}
+	}
+
+	return row, err
+}
+
+// set сохраняет value в backends. Пустое значение отмечает несуществующий код.
+func (s *Store) set(ctx context.Context, backends []Backend, short string, value []byte) {
+	ttl := s.opts.TTL
+
+	if len(value) == 0 {
+		ttl = s.opts.NegativeTTL
+	}
+
+	if ttl <= 0 {
+		return
+	}
+
+	for _, backend := range backends {
+		if err := backend.Set(ctx, short, value, ttl); err != nil {
+			logger.FromContext(ctx).Info("Cache set failed", zap.Error(err))
+		}
+	}
+}
+
+func decode(value []byte) (json.DBRow, error) {
+	row := json.DBRow{}
+
+	if len(value) == 0 {
+		return row, store.ErrNotFound
+	}
+
+	err := easyjson.Unmarshal(value, &row)
+
+	return row, err
+}
+
+// invalidate удаляет коды из всех кэшей. Изменение в хранилище уже сохранено, поэтому
+// ошибка кэша только записывается в лог: устаревшая запись истечёт через TTL.
+func (s *Store) invalidate(ctx context.Context, codes ...string) {
+	if len(codes) == 0 {
+		return
+	}
+
+	for _, backend := range s.backends {
+		if err := backend.Delete(ctx, codes...); err != nil {
+			logger.FromContext(ctx).Info("Cache delete failed", zap.Error(err))
+		}
+	}
+}
+
+func codesOf(rows json.BatchURLSlice) []string {
+	codes := make([]string, len(rows))
+
+	for i, row := range rows {
+		codes[i] = row.ShortURL
+	}
+
+	return codes
+}
+
+// SaveURL удаляет код новой ссылки из кэша, чтобы сбросить отметку о несуществующем коде.
+// Так же поступают остальные методы, добавляющие ссылки.
+func (s *Store) SaveURL(ctx context.Context, row json.DBRow) error {
+	err := s.Store.SaveURL(ctx, row)
+	s.invalidate(ctx, row.ShortURL)
+
+	return err
+}
+
+func (s *Store) SaveBatchURL(ctx context.Context, rows json.BatchURLSlice) error {
+	err := s.Store.SaveBatchURL(ctx, rows)
+	s.invalidate(ctx, codesOf(rows)...)
+
+	return err
+}
+
+func (s *Store) ImportURLs(ctx context.Context, rows json.BatchURLSlice) ([]string, error) {
+	skipped, err := s.Store.ImportURLs(ctx, rows)
+	s.invalidate(ctx, codesOf(rows)...)
+
+	return skipped, err
+}
+
+func (s *Store) RestoreURLs(ctx context.Context, rows json.BatchURLSlice) error {
+	err := s.Store.RestoreURLs(ctx, rows)
+	s.invalidate(ctx, codesOf(rows)...)
+
+	return err
+}
+
+func (s *Store) UpdateURL(ctx context.Context, row json.DBRow) error {
+	err := s.Store.UpdateURL(ctx, row)
+
+	if err == nil {
+		s.invalidate(ctx, row.ShortURL)
+	}
+
+	return err
+}
+
+func (s *Store) RenameTag(ctx context.Context, userID, oldName, newName string) error {
+	codes, err := s.taggedCodes(ctx, userID, oldName)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.Store.RenameTag(ctx, userID, oldName, newName); err != nil {
+		return err
+	}
+
+	s.invalidate(ctx, codes...)
+
+	return nil
+}
+
+func (s *Store) DeleteTag(ctx context.Context, userID, name string) error {
+	codes, err := s.taggedCodes(ctx, userID, name)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.Store.DeleteTag(ctx, userID, name); err != nil {
+		return err
+	}
+
+	s.invalidate(ctx, codes...)
+
+	return nil
+}
+
+// taggedCodes возвращает коды ссылок пользователя с тегом tag
+func (s *Store) taggedCodes(ctx context.Context, userID, tag string) ([]string, error) {
+	var codes []string
+
+	err := s.Store.Iterate(ctx, store.IterateOptions{UserID: userID}, func(row json.DBRow) error {
+		for _, t := range row.Tags {
+			if t == tag {
+				codes = append(codes, row.ShortURL)
+				break
+			}
+		}
+
+		return nil
+	})
+
+	return codes, err
+}
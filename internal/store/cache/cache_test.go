@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"github.com/laiker/shortener/internal/store/memory"
+	"github.com/laiker/shortener/internal/store/storetest"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// countingStore считает обращения к GetURL хранилища
+type countingStore struct {
+	store.Store
+	gets int
+}
+
+func (s *countingStore) GetURL(ctx context.Context, short string) (json.DBRow, error) {
+	s.gets++
+	return s.Store.GetURL(ctx, short)
+}
+
+func newBackingStore(t *testing.T) *countingStore {
+	s := memory.NewStore()
+	require.NoError(t, s.Bootstrap(context.Background()))
+	require.NoError(t, s.SaveURL(context.Background(), json.DBRow{
+		ShortURL:    "abc",
+		OriginalURL: "https://cache.ru/",
+		UserID:      "user",
+		Tags:        []string{"promo"},
+	}))
+
+	return &countingStore{Store: s}
+}
+
+func newRedis(t *testing.T) (*miniredis.Miniredis, *Redis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return server, NewRedis(client, "test:")
+}
+
+var options = Options{TTL: time.Minute, NegativeTTL: 10 * time.Second}
+
+func TestStore(t *testing.T) {
+	backends := map[string]func(t *testing.T) Backend{
+		"lru": func(t *testing.T) Backend { return NewLRU(100) },
+		"redis": func(t *testing.T) Backend {
+			_, backend := newRedis(t)
+			return backend
+		},
+	}
+
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			backing := newBackingStore(t)
+			s := NewStore(backing, options, newBackend(t))
+
+			for i := 0; i < 3; i++ {
+				row, err := s.GetURL(ctx, "abc")
+				require.NoError(t, err)
+				assert.Equal(t, "https://cache.ru/", row.OriginalURL)
+				assert.Equal(t, "user", row.UserID)
+			}
+
+			assert.Equal(t, 1, backing.gets)
+
+			for i := 0; i < 3; i++ {
+				_, err := s.GetURL(ctx, "new")
+				assert.ErrorIs(t, err, store.ErrNotFound)
+			}
+
+			assert.Equal(t, 2, backing.gets, "unknown codes are cached too")
+
+			require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "new", OriginalURL: "https://cache.ru/new", UserID: "user"}))
+
+			row, err := s.GetURL(ctx, "new")
+			require.NoError(t, err, "saving a link drops the negative entry")
+			assert.Equal(t, "https://cache.ru/new", row.OriginalURL)
+
+			row, err = s.GetURL(ctx, "abc")
+			require.NoError(t, err)
+			row.OriginalURL = "https://cache.ru/edited"
+			require.NoError(t, s.UpdateURL(ctx, row))
+
+			row, err = s.GetURL(ctx, "abc")
+			require.NoError(t, err)
+			assert.Equal(t, "https://cache.ru/edited", row.OriginalURL)
+
+			require.NoError(t, s.RenameTag(ctx, "user", "promo", "sale"))
+
+			row, err = s.GetURL(ctx, "abc")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"sale"}, row.Tags)
+
+			require.NoError(t, s.DeleteTag(ctx, "user", "sale"))
+
+			row, err = s.GetURL(ctx, "abc")
+			require.NoError(t, err)
+			assert.Empty(t, row.Tags)
+		})
+	}
+}
+
+func TestStoreTiers(t *testing.T) {
+	ctx := context.Background()
+	server, shared := newRedis(t)
+	backing := newBackingStore(t)
+
+	first := NewStore(backing, options, NewLRU(100), shared)
+	second := NewStore(backing, options, NewLRU(100), shared)
+
+	_, err := first.GetURL(ctx, "abc")
+	require.NoError(t, err)
+
+	_, err = second.GetURL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, 1, backing.gets, "the second instance reads the link from redis")
+
+	server.FlushAll()
+
+	_, err = second.GetURL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, 1, backing.gets, "the link is copied to the local cache")
+
+	server.Close()
+
+	_, err = NewStore(backing, options, shared).GetURL(ctx, "abc")
+	require.NoError(t, err, "redis failures fall back to the store")
+	assert.Equal(t, 2, backing.gets)
+}
+
+func TestStoreTTL(t *testing.T) {
+	ctx := context.Background()
+	server, backend := newRedis(t)
+	backing := newBackingStore(t)
+	s := NewStore(backing, options, backend)
+
+	_, err := s.GetURL(ctx, "missing")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.GetURL(ctx, "abc")
+	require.NoError(t, err)
+
+	server.FastForward(options.NegativeTTL)
+
+	_, err = s.GetURL(ctx, "missing")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.GetURL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, 3, backing.gets, "negative entries live shorter")
+
+	server.FastForward(options.TTL)
+
+	_, err = s.GetURL(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, 4, backing.gets)
+
+	s = NewStore(backing, Options{TTL: time.Minute}, backend)
+
+	for i := 0; i < 2; i++ {
+		_, err = s.GetURL(ctx, "other")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	}
+
+	assert.Equal(t, 6, backing.gets, "zero negative TTL disables negative caching")
+}
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+
+	_, ok, _ := c.Get(ctx, "a")
+	assert.True(t, ok)
+
+	require.NoError(t, c.Set(ctx, "c", []byte("3"), time.Minute))
+
+	_, ok, _ = c.Get(ctx, "b")
+	assert.False(t, ok, "the least recently used value is evicted")
+
+	value, ok, _ := c.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+
+	require.NoError(t, c.Set(ctx, "d", []byte("4"), time.Nanosecond))
+	time.Sleep(time.Millisecond)
+
+	_, ok, _ = c.Get(ctx, "d")
+	assert.False(t, ok, "expired values are dropped")
+
+	require.NoError(t, c.Delete(ctx, "a", "unknown"))
+
+	_, ok, _ = c.Get(ctx, "a")
+	assert.False(t, ok)
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return NewStore(memory.NewStore(), options, NewLRU(100))
+	})
+}
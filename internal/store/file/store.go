@@ -2,24 +2,48 @@ package file
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	json2 "encoding/json"
 	"errors"
+	"fmt"
 	"github.com/laiker/shortener/cmd/config"
 	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"io"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
+// Store хранит ссылки в файле JSON lines. Файл только дописывается: изменённая ссылка
+// записывается новой строкой с тем же кодом, актуальной считается последняя из них.
+// Версии, которые записаны при переименовании и удалении тегов, сохраняют UpdatedAt
+// прежней версии, поэтому в историю изменений ссылки не попадают.
 type Store struct {
 	filename string
 	file     *os.File
+	mu       sync.Mutex
+	lastID   int
+	// clicks файл только дописывается, поэтому счётчики переходов живут в памяти. Каждая новая
+	// версия ссылки записывается с текущим счётчиком, с него же счёт продолжается после перезапуска.
+	clicks map[string]int64
+	// codes занятые коды с адресами назначения их открытых ссылок, у ссылок с паролем адрес
+	// пустой. По ним импорт не перечитывает файл ради проверки каждой пачки
+	codes map[string]string
+	// originals код первой открытой ссылки на адрес назначения, чтобы не сохранять её повторно
+	originals map[string]string
 }
 
 func NewStore(filename string) *Store {
 	return &Store{
-		filename: filename,
-		file:     nil,
+		filename:  filename,
+		file:      nil,
+		clicks:    make(map[string]int64),
+		codes:     make(map[string]string),
+		originals: make(map[string]string),
 	}
 }
 
@@ -33,8 +57,19 @@ func (s *Store) PingContext(ctx context.Context) error {
 	return nil
 }
 
+// Writable проверяет, что файл хранилища можно открыть на запись
+func (s *Store) Writable() error {
+	file, err := os.OpenFile(s.filename, os.O_WRONLY|os.O_APPEND, 0)
+
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
 func (s *Store) Bootstrap(ctx context.Context) error {
-	file, err := os.OpenFile(config.StoragePath, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0775)
+	file, err := os.OpenFile(s.filename, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0775)
 
 	if err != nil {
 		return err
@@ -42,110 +77,507 @@ func (s *Store) Bootstrap(ctx context.Context) error {
 
 	s.file = file
 
+	// продолжаем нумерацию с последней записи в файле
+	return s.each(func(row json.DBRow) bool {
+		s.index(row)
+		s.clicks[row.ShortURL] = row.Clicks
+		return true
+	})
+}
+
+// index запоминает ID, код и адрес назначения записанной версии ссылки. Вызывается под s.mu
+func (s *Store) index(row json.DBRow) {
+	// прежний адрес изменённой ссылки освобождается
+	if previous := s.codes[row.ShortURL]; previous != "" && s.originals[previous] == row.ShortURL {
+		delete(s.originals, previous)
+	}
+
+	original := ""
+
+	if row.PasswordHash == "" {
+		original = row.OriginalURL
+	}
+
+	s.codes[row.ShortURL] = original
+
+	if _, ok := s.originals[original]; original != "" && !ok {
+		s.originals[original] = row.ShortURL
+	}
+
+	if row.ID > s.lastID {
+		s.lastID = row.ID
+	}
+}
+
+// write дописывает ссылки в файл одной записью и добавляет их в индексы. Вызывается под s.mu
+func (s *Store) write(rows json.BatchURLSlice) error {
+	var buf bytes.Buffer
+
+	encoder := json2.NewEncoder(&buf)
+
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		s.index(row)
+	}
+
 	return nil
 }
 
-func (s *Store) SaveURL(ctx context.Context, original, short string) error {
+// prepare заполняет поля новой ссылки перед записью
+func (s *Store) prepare(ctx context.Context, row json.DBRow, id int) json.DBRow {
+	if row.UserID == "" {
+		row.UserID, _ = ctx.Value(config.UserIDKey).(string)
+	}
+
+	if row.CreatedAt == nil {
+		now := time.Now()
+		row.CreatedAt = &now
+	}
+
+	row.ID = id
+	row.CorrelationID = ""
+	row.Clicks = 0
+
+	return row
+}
+
+// each читает файл с начала и передаёт записи в fn, пока она возвращает true
+func (s *Store) each(fn func(row json.DBRow) bool) error {
+	file, err := os.Open(s.filename)
+
+	if err != nil {
+		return err
+	}
 
-	encoder := json2.NewEncoder(s.file)
-	reader := bufio.NewReader(s.file)
+	defer file.Close()
 
-	var lastLine string
+	reader := bufio.NewReader(file)
 
 	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
+		line, errline := reader.ReadString('\n')
+
+		if errline != nil {
 			break // Достигнут конец файла
 		}
-		lastLine = line
-		if strings.TrimSpace(line) == "}" {
+
+		row := json.DBRow{}
+
+		if err := json2.Unmarshal([]byte(line), &row); err != nil {
+			continue
+		}
+
+		if !fn(row) {
 			break
 		}
 	}
 
-	lastRow := &json.DBRow{}
-	lastID := 1
-	if lastLine != "" {
-		if err := json2.Unmarshal([]byte(lastLine), &lastRow); err != nil {
-			return err
+	return nil
+}
+
+func (s *Store) SaveURL(ctx context.Context, row json.DBRow) error {
+	return s.SaveBatchURL(ctx, json.BatchURLSlice{row})
+}
+
+// SaveBatchURL проверяет всю пачку до записи, поэтому при ErrUnique и ErrCodeTaken не сохраняется
+// ни одна ссылка
+func (s *Store) SaveBatchURL(ctx context.Context, urls json.BatchURLSlice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows := make(json.BatchURLSlice, 0, len(urls))
+	// адреса и коды из пачки, чтобы повтор внутри неё тоже считался занятым
+	originals := make(map[string]string, len(urls))
+	codes := make(map[string]struct{}, len(urls))
+
+	for _, row := range urls {
+		if row.PasswordHash == "" {
+			if code, ok := s.originals[row.OriginalURL]; ok {
+				return &store.UniqueError{ShortURL: code}
+			}
+
+			if code, ok := originals[row.OriginalURL]; ok {
+				return &store.UniqueError{ShortURL: code}
+			}
+
+			originals[row.OriginalURL] = row.ShortURL
 		}
 
-		lastID = lastRow.ID + 1
+		_, saved := s.codes[row.ShortURL]
+		_, batched := codes[row.ShortURL]
+
+		if saved || batched {
+			return store.ErrCodeTaken
+		}
+
+		codes[row.ShortURL] = struct{}{}
+		rows = append(rows, s.prepare(ctx, row, s.lastID+len(rows)+1))
 	}
 
-	userID, _ := ctx.Value("userID").(string)
+	return s.write(rows)
+}
+
+func (s *Store) ImportURLs(ctx context.Context, urls json.BatchURLSlice) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var skipped []string
+
+	rows := make(json.BatchURLSlice, 0, len(urls))
+	batched := make(map[string]struct{}, len(urls))
 
-	row := &json.DBRow{
-		ID:          lastID,
-		OriginalURL: original,
-		ShortURL:    short,
-		UserID:      userID,
+	for _, row := range urls {
+		_, saved := s.codes[row.ShortURL]
+		_, repeated := batched[row.ShortURL]
+
+		if saved || repeated {
+			skipped = append(skipped, row.ShortURL)
+			continue
+		}
+
+		batched[row.ShortURL] = struct{}{}
+		rows = append(rows, s.prepare(ctx, row, s.lastID+len(rows)+1))
 	}
 
-	err := encoder.Encode(row)
+	return skipped, s.write(rows)
+}
+
+func (s *Store) GetURL(ctx context.Context, short string) (json.DBRow, error) {
+	found, err := s.getURL(short)
 
 	if err != nil {
-		return err
+		return found, err
 	}
 
-	return nil
+	s.mu.Lock()
+	found.Clicks = s.clicks[found.ShortURL]
+	s.mu.Unlock()
+
+	return found, nil
 }
 
-func (s *Store) SaveBatchURL(ctx context.Context, urls json.BatchURLSlice) error {
+// getURL читает из файла последнюю версию ссылки без счётчика переходов
+func (s *Store) getURL(short string) (json.DBRow, error) {
+	var found json.DBRow
+
+	err := s.each(func(row json.DBRow) bool {
+		// более поздние строки содержат изменённые версии ссылки
+		if row.ShortURL == strings.TrimSpace(short) {
+			found = row
+		}
+		return true
+	})
+
+	if err != nil {
+		return found, err
+	}
+
+	if found.OriginalURL == "" {
+		return found, store.ErrNotFound
+	}
+
+	return found, nil
+}
+
+func (s *Store) GetUserURLs(ctx context.Context, userID string) ([]json.DBRow, error) {
+	URLs, err := s.userURLs(userID)
+
+	s.mu.Lock()
+	for i := range URLs {
+		URLs[i].Clicks = s.clicks[URLs[i].ShortURL]
+	}
+	s.mu.Unlock()
+
+	return URLs, err
+}
+
+// userURLs читает из файла последние версии ссылок пользователя без счётчиков переходов
+func (s *Store) userURLs(userID string) ([]json.DBRow, error) {
+	var URLs []json.DBRow
+	positions := make(map[string]int)
+
+	err := s.each(func(row json.DBRow) bool {
+		if row.UserID != strings.TrimSpace(userID) {
+			return true
+		}
+
+		// изменённая ссылка заменяет прежнюю версию на её месте в списке
+		if i, ok := positions[row.ShortURL]; ok {
+			URLs[i] = row
+			return true
+		}
+
+		positions[row.ShortURL] = len(URLs)
+		URLs = append(URLs, row)
+		return true
+	})
+
+	return URLs, err
+}
+
+func (s *Store) ListUserURLs(ctx context.Context, opts store.ListOptions) ([]json.DBRow, error) {
+	rows, err := s.GetUserURLs(ctx, opts.UserID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return store.ListRows(rows, opts), nil
+}
+
+// Iterate читает файл дважды: сначала запоминает смещение последней версии каждой ссылки,
+// затем читает эти версии в порядке ID. В памяти держатся только коды и смещения строк.
+func (s *Store) Iterate(ctx context.Context, opts store.IterateOptions, fn func(row json.DBRow) error) error {
+	type position struct {
+		id     int
+		offset int64
+	}
+
+	file, err := os.Open(s.filename)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	latest := make(map[string]position)
+	reader := bufio.NewReader(file)
+
+	var offset int64
+
+	for {
+		line, errline := reader.ReadString('\n')
+
+		if errline != nil {
+			break
+		}
+
+		row := json.DBRow{}
+
+		if err := json2.Unmarshal([]byte(line), &row); err == nil && (opts.UserID == "" || row.UserID == opts.UserID) {
+			latest[row.ShortURL] = position{id: row.ID, offset: offset}
+		}
+
+		offset += int64(len(line))
+	}
+
+	positions := make([]position, 0, len(latest))
+
+	for _, p := range latest {
+		if p.id > opts.AfterID {
+			positions = append(positions, p)
+		}
+	}
+
+	sort.Slice(positions, func(i, j int) bool { return positions[i].id < positions[j].id })
+
+	for _, p := range positions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, err := file.Seek(p.offset, io.SeekStart); err != nil {
+			return err
+		}
+
+		reader.Reset(file)
+
+		line, err := reader.ReadString('\n')
 
-	for i := 0; i < len(urls); i++ {
-		err := s.SaveURL(ctx, urls[i].ShortURL, urls[i].OriginalURL)
 		if err != nil {
 			return err
 		}
+
+		row := json.DBRow{}
+
+		if err := json2.Unmarshal([]byte(line), &row); err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		row.Clicks = s.clicks[row.ShortURL]
+		s.mu.Unlock()
+
+		if err := fn(row); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (s *Store) GetURL(ctx context.Context, short string) (json.DBRow, error) {
-	reader := bufio.NewReader(s.file)
+// RestoreURLs дописывает ссылки в файл как есть. Файл упорядочен по ID, поэтому ID каждой
+// ссылки должен быть больше последнего сохранённого.
+func (s *Store) RestoreURLs(ctx context.Context, rows json.BatchURLSlice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	row := json.DBRow{}
+	lastID := s.lastID
+	batched := make(map[string]struct{}, len(rows))
+	restored := make(json.BatchURLSlice, 0, len(rows))
 
-	for {
-		line, err := reader.ReadString('\n')
-		switch err {
-		case nil:
-			if err := json2.Unmarshal([]byte(line), &row); err != nil {
-				return row, err // Ошибка декодирования строки в JSON
-			}
+	for _, row := range rows {
+		_, saved := s.codes[row.ShortURL]
+		_, repeated := batched[row.ShortURL]
 
-			// Если найдено совпадение, возвращаем OriginalURL
-			if row.ShortURL == strings.TrimSpace(short) {
-				return row, nil
-			}
-		default:
-			return row, errors.New("url not found") // Другая ошибка чтения файла
+		if saved || repeated || row.ID <= lastID {
+			return fmt.Errorf("%w: id %d, code %s", store.ErrUnique, row.ID, row.ShortURL)
 		}
+
+		lastID = row.ID
+		batched[row.ShortURL] = struct{}{}
+
+		row.CorrelationID = ""
+		restored = append(restored, row)
+	}
+
+	if err := s.write(restored); err != nil {
+		return err
+	}
+
+	for _, row := range restored {
+		s.clicks[row.ShortURL] = row.Clicks
 	}
+
+	return nil
 }
 
-func (s *Store) GetUserURLs(ctx context.Context, userID string) ([]json.DBRow, error) {
-	reader := bufio.NewReader(s.file)
+func (s *Store) Stats(ctx context.Context) (store.Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	var URLs []json.DBRow
-	row := json.DBRow{}
+	return store.Stats{Count: len(s.codes), LastID: s.lastID}, nil
+}
 
-	for {
+func (s *Store) IncrementClicks(ctx context.Context, short string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		line, errline := reader.ReadString('\n')
+	if _, ok := s.codes[short]; !ok {
+		return store.ErrNotFound
+	}
+
+	s.clicks[short]++
+
+	return nil
+}
+
+func (s *Store) UpdateURL(ctx context.Context, row json.DBRow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.getURL(row.ShortURL)
+
+	if err != nil {
+		return err
+	}
+
+	if current.UserID != row.UserID {
+		return store.ErrNotFound
+	}
+
+	now := time.Now()
+
+	current.OriginalURL = row.OriginalURL
+	current.RedirectType = row.RedirectType
+	current.ExpiresAt = row.ExpiresAt
+	current.Tags = row.Tags
+	current.UpdatedAt = &now
+	current.Clicks = s.clicks[current.ShortURL]
+
+	return s.write(json.BatchURLSlice{current})
+}
+
+func (s *Store) GetURLHistory(ctx context.Context, short string) ([]json.URLHistory, error) {
+	var versions []json.DBRow
+
+	err := s.each(func(row json.DBRow) bool {
+		if row.ShortURL == strings.TrimSpace(short) {
+			versions = append(versions, row)
+		}
+		return true
+	})
 
-		if err := json2.Unmarshal([]byte(line), &row); err != nil || errline != nil {
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]json.URLHistory, 0, len(versions))
+
+	// последняя строка — текущая версия, каждую прежнюю заменила следующая за ней
+	for i := len(versions) - 2; i >= 0; i-- {
+		if sameTime(versions[i].UpdatedAt, versions[i+1].UpdatedAt) {
 			continue
 		}
 
-		// Если найдено совпадение, возвращаем OriginalURL
-		if row.UserID == strings.TrimSpace(userID) {
-			URLs = append(URLs, row)
+		history = append(history, json.URLHistory{
+			Version:      i + 1,
+			OriginalURL:  versions[i].OriginalURL,
+			RedirectType: versions[i].RedirectType,
+			ExpiresAt:    versions[i].ExpiresAt,
+			Tags:         versions[i].Tags,
+			ChangedAt:    versions[i+1].UpdatedAt,
+		})
+	}
+
+	return history, nil
+}
+
+func (s *Store) RenameTag(ctx context.Context, userID, oldName, newName string) error {
+	return s.replaceTag(userID, oldName, newName)
+}
+
+func (s *Store) DeleteTag(ctx context.Context, userID, name string) error {
+	return s.replaceTag(userID, name, "")
+}
+
+// replaceTag дописывает новые версии всех ссылок пользователя с тегом oldName. Ссылки
+// читаются под s.mu, чтобы параллельное изменение не потерялось
+func (s *Store) replaceTag(userID, oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows, err := s.userURLs(userID)
+
+	if err != nil {
+		return err
+	}
+
+	changed := make(json.BatchURLSlice, 0, len(rows))
+
+	for _, row := range rows {
+		tags, ok := store.ReplaceTag(row.Tags, oldName, newName)
+
+		if !ok {
+			continue
 		}
 
+		row.Tags = tags
+		row.Clicks = s.clicks[row.ShortURL]
+		changed = append(changed, row)
+	}
+
+	if len(changed) == 0 {
+		return store.ErrNotFound
+	}
+
+	return s.write(changed)
+}
+
+// sameTime сообщает, что моменты a и b совпадают или оба не заданы
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
 
-	return URLs, nil
+	return a.Equal(*b)
 }
@@ -0,0 +1,47 @@
+package file
+
+import (
+	"context"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"github.com/laiker/shortener/internal/store/storetest"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s := NewStore(filepath.Join(t.TempDir(), "urls.json"))
+		t.Cleanup(func() {
+			if s.file != nil {
+				s.file.Close()
+			}
+		})
+
+		return s
+	})
+}
+
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "urls.json")
+
+	s := NewStore(filename)
+	require.NoError(t, s.Bootstrap(ctx))
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "a", OriginalURL: "https://reopen.ru/a", UserID: "alice"}))
+	require.NoError(t, s.UpdateURL(ctx, json.DBRow{ShortURL: "a", OriginalURL: "https://reopen.ru/b", UserID: "alice"}))
+	s.file.Close()
+
+	// индексы восстанавливаются из файла: прежний адрес свободен, новый занят
+	reopened := NewStore(filename)
+	require.NoError(t, reopened.Bootstrap(ctx))
+	defer reopened.file.Close()
+
+	require.NoError(t, reopened.SaveURL(ctx, json.DBRow{ShortURL: "c", OriginalURL: "https://reopen.ru/a", UserID: "alice"}))
+	require.ErrorIs(t, reopened.SaveURL(ctx, json.DBRow{ShortURL: "d", OriginalURL: "https://reopen.ru/b", UserID: "alice"}), store.ErrUnique)
+
+	row, err := reopened.GetURL(ctx, "c")
+	require.NoError(t, err)
+	require.Equal(t, 2, row.ID)
+}
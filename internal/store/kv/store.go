@@ -0,0 +1,533 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	json2 "encoding/json"
+	"errors"
+	"fmt"
+	"github.com/laiker/shortener/cmd/config"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"github.com/mailru/easyjson"
+	bolt "go.etcd.io/bbolt"
+	"io"
+	"time"
+)
+
+// Бакеты БД. Ссылка хранится только в urlsBucket, остальные бакеты индексы по ней.
+var (
+	// urlsBucket код → ссылка в JSON
+	urlsBucket = []byte("urls")
+	// idsBucket ID → код, задаёт порядок обхода ссылок
+	idsBucket = []byte("ids")
+	// originalBucket адрес назначения → код первой открытой ссылки на него
+	originalBucket = []byte("original")
+	// usersBucket пользователь, 0, код → пусто
+	usersBucket = []byte("users")
+	// historyBucket код, 0, номер версии → прежняя версия ссылки в JSON
+	historyBucket = []byte("history")
+)
+
+// iterateChunk число ссылок, которые Iterate читает за одну транзакцию. Долгая читающая
+// транзакция не даёт БД расти, поэтому обход не держит её, пока работает fn.
+const iterateChunk = 256
+
+// Store реализует интерфейс store.Store во встроенной БД bbolt
+type Store struct {
+	db *bolt.DB
+}
+
+// Open открывает файл БД. Файл блокируется, поэтому другой процесс ждёт его не дольше секунды.
+func Open(path string) (*bolt.DB, error) {
+	return bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+}
+
+// NewStore возвращает новый экземпляр bbolt хранилища
+func NewStore(db *bolt.DB) *Store {
+	return &Store{db: db}
+}
+
+func idKey(id int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+// prefixKey возвращает ключ prefix, 0, suffix индексов по пользователю и истории
+func prefixKey(prefix string, suffix []byte) []byte {
+	key := make([]byte, 0, len(prefix)+1+len(suffix))
+	key = append(key, prefix...)
+	key = append(key, 0)
+	return append(key, suffix...)
+}
+
+func (s *Store) PingContext(ctx context.Context) error {
+	return s.db.View(func(tx *bolt.Tx) error { return nil })
+}
+
+// Bootstrap создаёт бакеты, которых ещё нет
+func (s *Store) Bootstrap(ctx context.Context) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{urlsBucket, idsBucket, originalBucket, usersBucket, historyBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func getURL(tx *bolt.Tx, short string) (json.DBRow, error) {
+	row := json.DBRow{}
+	value := tx.Bucket(urlsBucket).Get([]byte(short))
+
+	if value == nil {
+		return row, store.ErrNotFound
+	}
+
+	err := easyjson.Unmarshal(value, &row)
+
+	return row, err
+}
+
+// putURL записывает ссылку и её индексы. Индекс адреса назначения указывает на первую
+// открытую ссылку: ссылки с паролем и импортированные повторы его не меняют.
+func putURL(tx *bolt.Tx, row json.DBRow) error {
+	value, err := easyjson.Marshal(row)
+
+	if err != nil {
+		return err
+	}
+
+	code := []byte(row.ShortURL)
+
+	if err := tx.Bucket(urlsBucket).Put(code, value); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(idsBucket).Put(idKey(row.ID), code); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(usersBucket).Put(prefixKey(row.UserID, code), nil); err != nil {
+		return err
+	}
+
+	original := tx.Bucket(originalBucket)
+
+	if row.PasswordHash == "" && original.Get([]byte(row.OriginalURL)) == nil {
+		return original.Put([]byte(row.OriginalURL), code)
+	}
+
+	return nil
+}
+
+func exists(tx *bolt.Tx, short string) bool {
+	return tx.Bucket(urlsBucket).Get([]byte(short)) != nil
+}
+
+func saveURL(ctx context.Context, tx *bolt.Tx, row json.DBRow) error {
+	if row.PasswordHash == "" {
+		if code := tx.Bucket(originalBucket).Get([]byte(row.OriginalURL)); code != nil {
+			return &store.UniqueError{ShortURL: string(code)}
+		}
+	}
+
+	if exists(tx, row.ShortURL) {
+		return store.ErrCodeTaken
+	}
+
+	if row.UserID == "" {
+		row.UserID, _ = ctx.Value(config.UserIDKey).(string)
+	}
+
+	if row.UserID == "" {
+		return errors.New("Не получен ID пользователя")
+	}
+
+	if row.CreatedAt == nil {
+		now := time.Now()
+		row.CreatedAt = &now
+	}
+
+	id, err := tx.Bucket(idsBucket).NextSequence()
+
+	if err != nil {
+		return err
+	}
+
+	row.ID = int(id)
+	row.CorrelationID = ""
+	row.Clicks = 0
+
+	return putURL(tx, row)
+}
+
+func (s *Store) SaveURL(ctx context.Context, row json.DBRow) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return saveURL(ctx, tx, row)
+	})
+}
+
+// SaveBatchURL сохраняет ссылки в одной транзакции: при ошибке не сохраняется ни одна
+func (s *Store) SaveBatchURL(ctx context.Context, urls json.BatchURLSlice) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, row := range urls {
+			if err := saveURL(ctx, tx, row); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *Store) ImportURLs(ctx context.Context, urls json.BatchURLSlice) ([]string, error) {
+	var skipped []string
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		skipped = nil
+
+		for _, row := range urls {
+			// повтор кода в пачке найдётся как уже сохранённый
+			if exists(tx, row.ShortURL) {
+				skipped = append(skipped, row.ShortURL)
+				continue
+			}
+
+			if row.CreatedAt == nil {
+				now := time.Now()
+				row.CreatedAt = &now
+			}
+
+			id, err := tx.Bucket(idsBucket).NextSequence()
+
+			if err != nil {
+				return err
+			}
+
+			row.ID = int(id)
+
+			if err := putURL(tx, row); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return skipped, err
+}
+
+func (s *Store) GetURL(ctx context.Context, short string) (json.DBRow, error) {
+	var row json.DBRow
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		row, err = getURL(tx, short)
+		return err
+	})
+
+	return row, err
+}
+
+// userURLs возвращает ссылки пользователя по индексу usersBucket
+func userURLs(tx *bolt.Tx, userID string) ([]json.DBRow, error) {
+	var URLs []json.DBRow
+
+	prefix := prefixKey(userID, nil)
+	cursor := tx.Bucket(usersBucket).Cursor()
+
+	for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+		row, err := getURL(tx, string(key[len(prefix):]))
+
+		if err != nil {
+			return URLs, err
+		}
+
+		URLs = append(URLs, row)
+	}
+
+	return URLs, nil
+}
+
+func (s *Store) GetUserURLs(ctx context.Context, userID string) ([]json.DBRow, error) {
+	var URLs []json.DBRow
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		URLs, err = userURLs(tx, userID)
+		return err
+	})
+
+	return URLs, err
+}
+
+func (s *Store) ListUserURLs(ctx context.Context, opts store.ListOptions) ([]json.DBRow, error) {
+	rows, err := s.GetUserURLs(ctx, opts.UserID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return store.ListRows(rows, opts), nil
+}
+
+// Iterate читает ссылки порциями по iterateChunk в отдельных транзакциях и продолжает
+// следующую порцию с ID, на котором остановилась предыдущая
+func (s *Store) Iterate(ctx context.Context, opts store.IterateOptions, fn func(row json.DBRow) error) error {
+	after := opts.AfterID
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		chunk := make([]json.DBRow, 0, iterateChunk)
+		last := after
+
+		err := s.db.View(func(tx *bolt.Tx) error {
+			cursor := tx.Bucket(idsBucket).Cursor()
+
+			for key, code := cursor.Seek(idKey(after + 1)); key != nil && len(chunk) < iterateChunk; key, code = cursor.Next() {
+				last = int(binary.BigEndian.Uint64(key))
+
+				row, err := getURL(tx, string(code))
+
+				if err != nil {
+					return err
+				}
+
+				if opts.UserID == "" || row.UserID == opts.UserID {
+					chunk = append(chunk, row)
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, row := range chunk {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+
+		if last == after {
+			return nil
+		}
+
+		after = last
+	}
+}
+
+// RestoreURLs сохраняет пачку в одной транзакции и сдвигает последовательность ID за
+// сохранённые, чтобы новые ссылки не получили занятый ID.
+func (s *Store) RestoreURLs(ctx context.Context, urls json.BatchURLSlice) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		ids := tx.Bucket(idsBucket)
+
+		for _, row := range urls {
+			if exists(tx, row.ShortURL) || ids.Get(idKey(row.ID)) != nil {
+				return fmt.Errorf("%w: id %d, code %s", store.ErrUnique, row.ID, row.ShortURL)
+			}
+
+			row.CorrelationID = ""
+
+			if err := putURL(tx, row); err != nil {
+				return err
+			}
+
+			if uint64(row.ID) > ids.Sequence() {
+				if err := ids.SetSequence(uint64(row.ID)); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *Store) Stats(ctx context.Context) (store.Stats, error) {
+	stats := store.Stats{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		stats.Count = tx.Bucket(urlsBucket).Stats().KeyN
+
+		if key, _ := tx.Bucket(idsBucket).Cursor().Last(); key != nil {
+			stats.LastID = int(binary.BigEndian.Uint64(key))
+		}
+
+		return nil
+	})
+
+	return stats, err
+}
+
+// IncrementClicks объединяет параллельные переходы в одну транзакцию через db.Batch, чтобы
+// переходы не ждали по очереди запись каждой транзакции на диск
+func (s *Store) IncrementClicks(ctx context.Context, short string) error {
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		row, err := getURL(tx, short)
+
+		if err != nil {
+			return err
+		}
+
+		row.Clicks++
+
+		value, err := easyjson.Marshal(row)
+
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(urlsBucket).Put([]byte(short), value)
+	})
+}
+
+func (s *Store) UpdateURL(ctx context.Context, row json.DBRow) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		current, err := getURL(tx, row.ShortURL)
+
+		if err != nil {
+			return err
+		}
+
+		if current.UserID != row.UserID {
+			return store.ErrNotFound
+		}
+
+		history := tx.Bucket(historyBucket)
+		version, err := history.NextSequence()
+
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		value, err := json2.Marshal(json.URLHistory{
+			Version:      int(version),
+			OriginalURL:  current.OriginalURL,
+			RedirectType: current.RedirectType,
+			ExpiresAt:    current.ExpiresAt,
+			Tags:         current.Tags,
+			ChangedAt:    &now,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if err := history.Put(prefixKey(row.ShortURL, idKey(int(version))), value); err != nil {
+			return err
+		}
+
+		original := tx.Bucket(originalBucket)
+
+		// индекс адреса назначения больше не должен указывать на эту ссылку
+		if string(original.Get([]byte(current.OriginalURL))) == current.ShortURL && current.OriginalURL != row.OriginalURL {
+			if err := original.Delete([]byte(current.OriginalURL)); err != nil {
+				return err
+			}
+		}
+
+		current.OriginalURL = row.OriginalURL
+		current.RedirectType = row.RedirectType
+		current.ExpiresAt = row.ExpiresAt
+		current.Tags = row.Tags
+		current.UpdatedAt = &now
+
+		return putURL(tx, current)
+	})
+}
+
+func (s *Store) GetURLHistory(ctx context.Context, short string) ([]json.URLHistory, error) {
+	history := make([]json.URLHistory, 0)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := prefixKey(short, nil)
+		cursor := tx.Bucket(historyBucket).Cursor()
+
+		for key, value := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, value = cursor.Next() {
+			version := json.URLHistory{}
+
+			if err := json2.Unmarshal(value, &version); err != nil {
+				return err
+			}
+
+			history = append(history, version)
+		}
+
+		return nil
+	})
+
+	// версии записаны от старых к новым, а возвращаются начиная с последней
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+
+	return history, err
+}
+
+func (s *Store) RenameTag(ctx context.Context, userID, oldName, newName string) error {
+	return s.replaceTag(userID, oldName, newName)
+}
+
+func (s *Store) DeleteTag(ctx context.Context, userID, name string) error {
+	return s.replaceTag(userID, name, "")
+}
+
+func (s *Store) replaceTag(userID, oldName, newName string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		rows, err := userURLs(tx, userID)
+
+		if err != nil {
+			return err
+		}
+
+		found := false
+
+		for _, row := range rows {
+			tags, ok := store.ReplaceTag(row.Tags, oldName, newName)
+
+			if !ok {
+				continue
+			}
+
+			found = true
+			row.Tags = tags
+
+			if err := putURL(tx, row); err != nil {
+				return err
+			}
+		}
+
+		if !found {
+			return store.ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+// Backup записывает в w согласованную копию БД, не останавливая запись в неё
+func (s *Store) Backup(ctx context.Context, w io.Writer) (int64, error) {
+	var size int64
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		size, err = tx.WriteTo(w)
+		return err
+	})
+
+	return size, err
+}
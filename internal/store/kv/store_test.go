@@ -0,0 +1,115 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"github.com/laiker/shortener/internal/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		db, err := Open(filepath.Join(t.TempDir(), "urls.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		return NewStore(db)
+	})
+}
+
+func TestBackup(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := Open(filepath.Join(t.TempDir(), "urls.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	s := NewStore(db)
+	require.NoError(t, s.Bootstrap(ctx))
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "a", OriginalURL: "https://a.ru", UserID: "u1"}))
+
+	var backup bytes.Buffer
+	size, err := s.Backup(ctx, &backup)
+	require.NoError(t, err)
+	require.Equal(t, int64(backup.Len()), size)
+
+	// запись после копии в неё не попадает
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "b", OriginalURL: "https://b.ru", UserID: "u1"}))
+
+	filename := filepath.Join(t.TempDir(), "backup.db")
+	require.NoError(t, os.WriteFile(filename, backup.Bytes(), 0600))
+
+	restored, err := Open(filename)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	copied := NewStore(restored)
+	row, err := copied.GetURL(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, "https://a.ru", row.OriginalURL)
+
+	_, err = copied.GetURL(ctx, "b")
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	// последовательность ID копируется вместе с данными
+	require.NoError(t, copied.SaveURL(ctx, json.DBRow{ShortURL: "c", OriginalURL: "https://c.ru", UserID: "u1"}))
+	row, err = copied.GetURL(ctx, "c")
+	require.NoError(t, err)
+	require.Equal(t, 2, row.ID)
+}
+
+func TestIncrementClicksBatch(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := Open(filepath.Join(t.TempDir(), "urls.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	s := NewStore(db)
+	require.NoError(t, s.Bootstrap(ctx))
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "hot", OriginalURL: "https://hot.ru", UserID: "u1"}))
+
+	// параллельные переходы объединяются в общие транзакции и не теряются
+	var wg sync.WaitGroup
+
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.IncrementClicks(ctx, "hot"))
+		}()
+	}
+
+	wg.Wait()
+
+	row, err := s.GetURL(ctx, "hot")
+	require.NoError(t, err)
+	require.Equal(t, int64(100), row.Clicks)
+
+	// ошибка одного перехода не отменяет остальные в той же транзакции
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		assert.ErrorIs(t, s.IncrementClicks(ctx, "missing"), store.ErrNotFound)
+	}()
+
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, s.IncrementClicks(ctx, "hot"))
+	}()
+
+	wg.Wait()
+
+	row, err = s.GetURL(ctx, "hot")
+	require.NoError(t, err)
+	require.Equal(t, int64(101), row.Clicks)
+}
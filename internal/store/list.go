@@ -0,0 +1,128 @@
+package store
+
+import (
+	"github.com/laiker/shortener/internal/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Поля, по которым сортируется список ссылок пользователя
+const (
+	SortCreated = "created"
+	SortClicks  = "clicks"
+)
+
+// Cursor ключ последней ссылки предыдущей страницы. Следующая страница начинается
+// сразу после него, поэтому новые ссылки не сдвигают уже выданные страницы.
+type Cursor struct {
+	CreatedAt time.Time
+	Clicks    int64
+	ID        int
+}
+
+// ListOptions параметры постраничного списка ссылок пользователя
+type ListOptions struct {
+	UserID string
+	// Tag оставляет только ссылки с этим тегом
+	Tag string
+	// Search оставляет только ссылки, адрес назначения которых содержит строку без учёта регистра
+	Search string
+	// Sort поле сортировки: SortCreated или SortClicks
+	Sort string
+	Desc bool
+	// After курсор предыдущей страницы, nil для первой страницы
+	After *Cursor
+	// Limit наибольшее число ссылок, 0 не ограничивает
+	Limit int
+}
+
+// CursorOf возвращает курсор, указывающий на ссылку row
+func CursorOf(row json.DBRow) Cursor {
+	cursor := Cursor{Clicks: row.Clicks, ID: row.ID}
+
+	if row.CreatedAt != nil {
+		cursor.CreatedAt = *row.CreatedAt
+	}
+
+	return cursor
+}
+
+// compare сравнивает ключи сортировки a и b: -1, если a идёт раньше b по возрастанию
+func (opts ListOptions) compare(a, b Cursor) int {
+	if opts.Sort == SortClicks {
+		if a.Clicks != b.Clicks {
+			if a.Clicks < b.Clicks {
+				return -1
+			}
+			return 1
+		}
+	} else if !a.CreatedAt.Equal(b.CreatedAt) {
+		if a.CreatedAt.Before(b.CreatedAt) {
+			return -1
+		}
+		return 1
+	}
+
+	switch {
+	case a.ID < b.ID:
+		return -1
+	case a.ID > b.ID:
+		return 1
+	}
+
+	return 0
+}
+
+// ListRows строит страницу списка из всех ссылок пользователя rows. Используется хранилищами,
+// которые не умеют фильтровать и сортировать ссылки на своей стороне.
+func ListRows(rows []json.DBRow, opts ListOptions) []json.DBRow {
+	search := strings.ToLower(opts.Search)
+	result := make([]json.DBRow, 0)
+
+	for _, row := range rows {
+		if opts.Tag != "" && !hasTag(row, opts.Tag) {
+			continue
+		}
+
+		if search != "" && !strings.Contains(strings.ToLower(row.OriginalURL), search) {
+			continue
+		}
+
+		if opts.After != nil {
+			order := opts.compare(CursorOf(row), *opts.After)
+
+			if (opts.Desc && order >= 0) || (!opts.Desc && order <= 0) {
+				continue
+			}
+		}
+
+		result = append(result, row)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		order := opts.compare(CursorOf(result[i]), CursorOf(result[j]))
+
+		if opts.Desc {
+			return order > 0
+		}
+
+		return order < 0
+	})
+
+	if opts.Limit > 0 && len(result) > opts.Limit {
+		result = result[:opts.Limit]
+	}
+
+	return result
+}
+
+func hasTag(row json.DBRow, tag string) bool {
+	for _, t := range row.Tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
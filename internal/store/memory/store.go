@@ -2,22 +2,33 @@ package memory
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"github.com/laiker/shortener/cmd/config"
 	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 type data map[string]json.DBRow
 
+// Store хранит ссылки в памяти процесса
 type Store struct {
+	mu sync.RWMutex
 	data
+	// history прежние версии ссылок по коду, от старых к новым
+	history map[string][]json.URLHistory
+	// lastID последний выданный ID, после RestoreURLs ID ссылок идут с пропусками
+	lastID int
 }
 
-// NewStore возвращает новый экземпляр PostgreSQL хранилища
+// NewStore возвращает новый экземпляр хранилища в памяти
 func NewStore() *Store {
 	return &Store{
-		data: make(data, 0),
+		data:    make(data, 0),
+		history: make(map[string][]json.URLHistory),
 	}
 }
 
@@ -26,30 +37,87 @@ func (s *Store) PingContext(ctx context.Context) error {
 }
 
 func (s *Store) Bootstrap(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.data = make(data, 0)
+	s.history = make(map[string][]json.URLHistory)
+	s.lastID = 0
 	return nil
 }
 
-func (s *Store) SaveURL(ctx context.Context, original, short string) error {
+// originalCode возвращает код открытой ссылки на original, если она есть. Вызывается под s.mu
+func (s *Store) originalCode(original string) (string, bool) {
+	for _, row := range s.data {
+		if row.PasswordHash == "" && row.OriginalURL == original {
+			return row.ShortURL, true
+		}
+	}
+
+	return "", false
+}
+
+// put сохраняет новую ссылку со следующим ID. Вызывается под s.mu
+func (s *Store) put(ctx context.Context, row json.DBRow) {
+	if row.UserID == "" {
+		row.UserID, _ = ctx.Value(config.UserIDKey).(string)
+	}
 
-	userID, _ := ctx.Value(config.UserIDKey).(string)
+	if row.CreatedAt == nil {
+		now := time.Now()
+		row.CreatedAt = &now
+	}
+
+	s.lastID++
+
+	row.ID = s.lastID
+	row.CorrelationID = ""
+	row.Clicks = 0
+
+	s.data[row.ShortURL] = row
+}
+
+// saveURL сохраняет ссылку, если открытой ссылки на тот же адрес ещё нет. Вызывается под s.mu
+func (s *Store) saveURL(ctx context.Context, row json.DBRow) error {
+	if row.PasswordHash == "" {
+		if code, ok := s.originalCode(row.OriginalURL); ok {
+			return &store.UniqueError{ShortURL: code}
+		}
+	}
 
-	url := json.DBRow{
-		ID:          len(s.data) + 1,
-		OriginalURL: original,
-		ShortURL:    short,
-		UserID:      userID,
+	if _, ok := s.data[row.ShortURL]; ok {
+		return store.ErrCodeTaken
 	}
 
-	s.data[short] = url
+	s.put(ctx, row)
 
 	return nil
 }
 
+func (s *Store) SaveURL(ctx context.Context, row json.DBRow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.saveURL(ctx, row)
+}
+
+// SaveBatchURL сохраняет все ссылки или ни одной: при ошибке сохранённые удаляются
 func (s *Store) SaveBatchURL(ctx context.Context, urls json.BatchURLSlice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lastID := s.lastID
+
 	for i := 0; i < len(urls); i++ {
-		err := s.SaveURL(ctx, urls[i].ShortURL, urls[i].OriginalURL)
+		err := s.saveURL(ctx, urls[i])
+
 		if err != nil {
+			for _, saved := range urls[:i] {
+				delete(s.data, saved.ShortURL)
+			}
+
+			s.lastID = lastID
+
 			return err
 		}
 	}
@@ -57,18 +125,41 @@ func (s *Store) SaveBatchURL(ctx context.Context, urls json.BatchURLSlice) error
 	return nil
 }
 
+func (s *Store) ImportURLs(ctx context.Context, rows json.BatchURLSlice) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var skipped []string
+
+	for _, row := range rows {
+		if _, ok := s.data[row.ShortURL]; ok {
+			skipped = append(skipped, row.ShortURL)
+			continue
+		}
+
+		s.put(ctx, row)
+	}
+
+	return skipped, nil
+}
+
 func (s *Store) GetURL(ctx context.Context, short string) (json.DBRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
 	dbRow := s.data[short]
 
 	if len(dbRow.OriginalURL) <= 0 {
-		return dbRow, errors.New("url not fund")
+		return dbRow, store.ErrNotFound
 	}
 
 	return dbRow, nil
 }
 
 func (s *Store) GetUserURLs(ctx context.Context, userID string) ([]json.DBRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	var URLs []json.DBRow
 
 	for _, row := range s.data {
@@ -79,3 +170,180 @@ func (s *Store) GetUserURLs(ctx context.Context, userID string) ([]json.DBRow, e
 
 	return URLs, nil
 }
+
+func (s *Store) ListUserURLs(ctx context.Context, opts store.ListOptions) ([]json.DBRow, error) {
+	rows, err := s.GetUserURLs(ctx, opts.UserID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return store.ListRows(rows, opts), nil
+}
+
+// Iterate передаёт в fn копии ссылок, поэтому fn может менять хранилище
+func (s *Store) Iterate(ctx context.Context, opts store.IterateOptions, fn func(row json.DBRow) error) error {
+	s.mu.RLock()
+	rows := make([]json.DBRow, 0, len(s.data))
+
+	for _, row := range s.data {
+		if row.ID > opts.AfterID && (opts.UserID == "" || row.UserID == opts.UserID) {
+			rows = append(rows, row)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+
+	for _, row := range rows {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) RestoreURLs(ctx context.Context, rows json.BatchURLSlice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make(map[int]struct{}, len(s.data))
+
+	for _, row := range s.data {
+		ids[row.ID] = struct{}{}
+	}
+
+	for _, row := range rows {
+		_, codeExists := s.data[row.ShortURL]
+		_, idExists := ids[row.ID]
+
+		if codeExists || idExists {
+			return fmt.Errorf("%w: id %d, code %s", store.ErrUnique, row.ID, row.ShortURL)
+		}
+
+		row.CorrelationID = ""
+		s.data[row.ShortURL] = row
+		ids[row.ID] = struct{}{}
+
+		if row.ID > s.lastID {
+			s.lastID = row.ID
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) Stats(ctx context.Context) (store.Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := store.Stats{Count: len(s.data)}
+
+	for _, row := range s.data {
+		if row.ID > stats.LastID {
+			stats.LastID = row.ID
+		}
+	}
+
+	return stats, nil
+}
+
+func (s *Store) IncrementClicks(ctx context.Context, short string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dbRow, ok := s.data[short]
+
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	dbRow.Clicks++
+	s.data[short] = dbRow
+
+	return nil
+}
+
+func (s *Store) UpdateURL(ctx context.Context, row json.DBRow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.data[row.ShortURL]
+
+	if !ok || current.UserID != row.UserID {
+		return store.ErrNotFound
+	}
+
+	now := time.Now()
+
+	s.history[row.ShortURL] = append(s.history[row.ShortURL], json.URLHistory{
+		Version:      len(s.history[row.ShortURL]) + 1,
+		OriginalURL:  current.OriginalURL,
+		RedirectType: current.RedirectType,
+		ExpiresAt:    current.ExpiresAt,
+		Tags:         current.Tags,
+		ChangedAt:    &now,
+	})
+
+	current.OriginalURL = row.OriginalURL
+	current.RedirectType = row.RedirectType
+	current.ExpiresAt = row.ExpiresAt
+	current.Tags = row.Tags
+	current.UpdatedAt = &now
+
+	s.data[row.ShortURL] = current
+
+	return nil
+}
+
+func (s *Store) GetURLHistory(ctx context.Context, short string) ([]json.URLHistory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := s.history[short]
+	history := make([]json.URLHistory, 0, len(versions))
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		history = append(history, versions[i])
+	}
+
+	return history, nil
+}
+
+func (s *Store) RenameTag(ctx context.Context, userID, oldName, newName string) error {
+	return s.replaceTag(userID, oldName, newName)
+}
+
+func (s *Store) DeleteTag(ctx context.Context, userID, name string) error {
+	return s.replaceTag(userID, name, "")
+}
+
+func (s *Store) replaceTag(userID, oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := false
+
+	for short, row := range s.data {
+		if row.UserID != userID {
+			continue
+		}
+
+		tags, ok := store.ReplaceTag(row.Tags, oldName, newName)
+
+		if !ok {
+			continue
+		}
+
+		found = true
+		row.Tags = tags
+		s.data[short] = row
+	}
+
+	if !found {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"github.com/laiker/shortener/internal/store"
+	"github.com/laiker/shortener/internal/store/storetest"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return NewStore()
+	})
+}
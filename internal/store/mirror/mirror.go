@@ -0,0 +1,393 @@
+// Package mirror повторяет изменения основного хранилища во втором хранилище
+package mirror
+
+import (
+	"context"
+	"errors"
+	logger "github.com/laiker/shortener/internal"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Options настройки повтора изменений в зеркале
+type Options struct {
+	// QueueSize наибольшее число изменений, ждущих записи в зеркало, 0 не ограничивает очередь.
+	// Изменения сверх него отбрасываются, отставшее зеркало можно догнать командой migrate-store
+	QueueSize int
+	// RetryInterval пауза перед повтором изменения, которое не удалось записать в зеркало
+	RetryInterval time.Duration
+	// CheckInterval период проверки основного хранилища через PingContext
+	CheckInterval time.Duration
+}
+
+// defaultInterval пауза повтора и период проверки, если они не заданы
+const defaultInterval = time.Second
+
+// operation изменение, которое нужно повторить в зеркале
+type operation struct {
+	method string
+	// ctx контекст запроса без отмены: в нём пользователь и ID запроса для логов
+	ctx   context.Context
+	apply func(ctx context.Context, s store.Store) error
+}
+
+// Store пишет в основное хранилище и после каждого успешного изменения ставит тот же вызов
+// в очередь зеркала. Очередь разбирает Run: изменения пишутся в зеркало по порядку, при ошибке
+// зеркала изменение повторяется, пока не будет записано. ID ссылок зеркало выдаёт само.
+//
+// Пока основное хранилище не отвечает на PingContext, чтение идёт из зеркала, а запись
+// по-прежнему в основное хранилище, поэтому завершается ошибкой.
+//
+// Store встраивает основное хранилище: новый метод store.Store без обёртки здесь работает только
+// с ним. Изменение ссылок таким методом не дойдёт до зеркала, а чтение не переключится на зеркало
+// при отказе основного хранилища.
+type Store struct {
+	store.Store
+	mirror store.Store
+	opts   Options
+
+	mu    sync.Mutex
+	queue []operation
+	// wake будит Run после добавления изменения в пустую очередь
+	wake chan struct{}
+
+	// primaryDown основное хранилище не ответило на последнюю проверку
+	primaryDown atomic.Bool
+	dropped     atomic.Int64
+	// running Run работает
+	running atomic.Bool
+}
+
+// NewStore возвращает хранилище primary, изменения которого повторяются в mirror.
+// Изменения пишутся в mirror, только пока работает Run.
+func NewStore(primary, mirror store.Store, opts Options) *Store {
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = defaultInterval
+	}
+
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = defaultInterval
+	}
+
+	return &Store{
+		Store:  primary,
+		mirror: mirror,
+		opts:   opts,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Unwrap возвращает основное хранилище
+func (s *Store) Unwrap() store.Store {
+	return s.Store
+}
+
+// Pending возвращает число изменений, ещё не записанных в зеркало
+func (s *Store) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.queue)
+}
+
+// Dropped возвращает число изменений, отброшенных из-за переполненной очереди
+func (s *Store) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Running сообщает, что Run работает и разбирает очередь
+func (s *Store) Running() bool {
+	return s.running.Load()
+}
+
+// PrimaryDown сообщает, что основное хранилище не ответило на последнюю проверку
+func (s *Store) PrimaryDown() bool {
+	return s.primaryDown.Load()
+}
+
+// enqueue ставит изменение в очередь зеркала
+func (s *Store) enqueue(ctx context.Context, method string, apply func(ctx context.Context, s store.Store) error) {
+	s.mu.Lock()
+
+	if s.opts.QueueSize > 0 && len(s.queue) >= s.opts.QueueSize {
+		s.mu.Unlock()
+		s.dropped.Add(1)
+		logger.FromContext(ctx).Info("Mirror queue is full, change dropped", zap.String("method", method))
+		return
+	}
+
+	s.queue = append(s.queue, operation{method: method, ctx: context.WithoutCancel(ctx), apply: apply})
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// permanent сообщает, что повтор изменения в зеркале не поможет: ссылка или её код там уже
+// есть или ссылки там нет, например, потому что зеркало подключили позже основного хранилища
+func permanent(err error) bool {
+	return errors.Is(err, store.ErrUnique) || errors.Is(err, store.ErrCodeTaken) || errors.Is(err, store.ErrNotFound)
+}
+
+// replayRows записывает ссылки пачки по одной, если зеркало отклонило пачку целиком из-за
+// ошибки err, которую повтор не исправит. Так в зеркало попадают остальные ссылки пачки,
+// а отклонённые по той же причине пропускаются.
+func replayRows(ctx context.Context, err error, rows json.BatchURLSlice, save func(row json.DBRow) error) error {
+	if !permanent(err) {
+		return err
+	}
+
+	for _, row := range rows {
+		if err := save(row); err != nil {
+			if !permanent(err) {
+				return err
+			}
+
+			logger.FromContext(ctx).Info("Mirror row skipped", zap.String("short_url", row.ShortURL), zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// Run пишет изменения из очереди в зеркало и проверяет основное хранилище, пока не отменён ctx
+func (s *Store) Run(ctx context.Context) {
+	s.running.Store(true)
+	defer s.running.Store(false)
+
+	check := time.NewTicker(s.opts.CheckInterval)
+	defer check.Stop()
+
+	for {
+		s.mu.Lock()
+		empty := len(s.queue) == 0
+		var op operation
+
+		if !empty {
+			op = s.queue[0]
+		}
+		s.mu.Unlock()
+
+		if empty {
+			select {
+			case <-ctx.Done():
+				return
+			case <-check.C:
+				s.check(ctx)
+			case <-s.wake:
+			}
+
+			continue
+		}
+
+		err := op.apply(op.ctx, s.mirror)
+
+		if err != nil && !permanent(err) {
+			logger.FromContext(op.ctx).Info("Mirror write failed, retrying", zap.String("method", op.method), zap.Error(err))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-check.C:
+				s.check(ctx)
+			case <-time.After(s.opts.RetryInterval):
+			}
+
+			continue
+		}
+
+		if err != nil {
+			logger.FromContext(op.ctx).Info("Mirror write skipped", zap.String("method", op.method), zap.Error(err))
+		}
+
+		s.mu.Lock()
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+	}
+}
+
+// check проверяет основное хранилище и записывает в лог смену его состояния
+func (s *Store) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.CheckInterval)
+	defer cancel()
+
+	err := s.Store.PingContext(ctx)
+
+	if down := err != nil; s.primaryDown.Swap(down) != down {
+		if down {
+			logger.Log.Info("Primary store is down, reading from mirror", zap.Error(err))
+		} else {
+			logger.Log.Info("Primary store is up")
+		}
+	}
+}
+
+// Flush ждёт, пока очередь зеркала опустеет, или отмены ctx
+func (s *Store) Flush(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for s.Pending() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+// reader возвращает хранилище для чтения: основное, пока оно отвечает на проверки
+func (s *Store) reader() store.Store {
+	if s.primaryDown.Load() {
+		return s.mirror
+	}
+
+	return s.Store
+}
+
+// PingReader проверяет хранилище, из которого сейчас читаются ссылки
+func (s *Store) PingReader(ctx context.Context) error {
+	return s.reader().PingContext(ctx)
+}
+
+func (s *Store) Bootstrap(ctx context.Context) error {
+	if err := s.Store.Bootstrap(ctx); err != nil {
+		return err
+	}
+
+	return s.mirror.Bootstrap(ctx)
+}
+
+func (s *Store) GetURL(ctx context.Context, short string) (json.DBRow, error) {
+	return s.reader().GetURL(ctx, short)
+}
+
+func (s *Store) GetUserURLs(ctx context.Context, userID string) ([]json.DBRow, error) {
+	return s.reader().GetUserURLs(ctx, userID)
+}
+
+func (s *Store) ListUserURLs(ctx context.Context, opts store.ListOptions) ([]json.DBRow, error) {
+	return s.reader().ListUserURLs(ctx, opts)
+}
+
+func (s *Store) GetURLHistory(ctx context.Context, short string) ([]json.URLHistory, error) {
+	return s.reader().GetURLHistory(ctx, short)
+}
+
+func (s *Store) SaveURL(ctx context.Context, row json.DBRow) error {
+	if err := s.Store.SaveURL(ctx, row); err != nil {
+		return err
+	}
+
+	s.enqueue(ctx, "SaveURL", func(ctx context.Context, m store.Store) error {
+		return m.SaveURL(ctx, row)
+	})
+
+	return nil
+}
+
+func (s *Store) SaveBatchURL(ctx context.Context, rows json.BatchURLSlice) error {
+	if err := s.Store.SaveBatchURL(ctx, rows); err != nil {
+		return err
+	}
+
+	// вызывающий может переиспользовать срез после возврата
+	rows = append(json.BatchURLSlice(nil), rows...)
+
+	s.enqueue(ctx, "SaveBatchURL", func(ctx context.Context, m store.Store) error {
+		return replayRows(ctx, m.SaveBatchURL(ctx, rows), rows, func(row json.DBRow) error {
+			return m.SaveURL(ctx, row)
+		})
+	})
+
+	return nil
+}
+
+func (s *Store) ImportURLs(ctx context.Context, rows json.BatchURLSlice) ([]string, error) {
+	skipped, err := s.Store.ImportURLs(ctx, rows)
+
+	if err != nil {
+		return skipped, err
+	}
+
+	rows = append(json.BatchURLSlice(nil), rows...)
+
+	s.enqueue(ctx, "ImportURLs", func(ctx context.Context, m store.Store) error {
+		_, err := m.ImportURLs(ctx, rows)
+		return err
+	})
+
+	return skipped, nil
+}
+
+func (s *Store) RestoreURLs(ctx context.Context, rows json.BatchURLSlice) error {
+	if err := s.Store.RestoreURLs(ctx, rows); err != nil {
+		return err
+	}
+
+	rows = append(json.BatchURLSlice(nil), rows...)
+
+	s.enqueue(ctx, "RestoreURLs", func(ctx context.Context, m store.Store) error {
+		return replayRows(ctx, m.RestoreURLs(ctx, rows), rows, func(row json.DBRow) error {
+			return m.RestoreURLs(ctx, json.BatchURLSlice{row})
+		})
+	})
+
+	return nil
+}
+
+func (s *Store) IncrementClicks(ctx context.Context, short string) error {
+	if err := s.Store.IncrementClicks(ctx, short); err != nil {
+		return err
+	}
+
+	s.enqueue(ctx, "IncrementClicks", func(ctx context.Context, m store.Store) error {
+		return m.IncrementClicks(ctx, short)
+	})
+
+	return nil
+}
+
+func (s *Store) UpdateURL(ctx context.Context, row json.DBRow) error {
+	if err := s.Store.UpdateURL(ctx, row); err != nil {
+		return err
+	}
+
+	s.enqueue(ctx, "UpdateURL", func(ctx context.Context, m store.Store) error {
+		return m.UpdateURL(ctx, row)
+	})
+
+	return nil
+}
+
+func (s *Store) RenameTag(ctx context.Context, userID, oldName, newName string) error {
+	if err := s.Store.RenameTag(ctx, userID, oldName, newName); err != nil {
+		return err
+	}
+
+	s.enqueue(ctx, "RenameTag", func(ctx context.Context, m store.Store) error {
+		return m.RenameTag(ctx, userID, oldName, newName)
+	})
+
+	return nil
+}
+
+func (s *Store) DeleteTag(ctx context.Context, userID, name string) error {
+	if err := s.Store.DeleteTag(ctx, userID, name); err != nil {
+		return err
+	}
+
+	s.enqueue(ctx, "DeleteTag", func(ctx context.Context, m store.Store) error {
+		return m.DeleteTag(ctx, userID, name)
+	})
+
+	return nil
+}
@@ -0,0 +1,268 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"github.com/laiker/shortener/cmd/config"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"github.com/laiker/shortener/internal/store/memory"
+	"github.com/laiker/shortener/internal/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyStore хранилище, которое отвечает ошибкой, пока down равен true
+type flakyStore struct {
+	store.Store
+	down  atomic.Bool
+	calls atomic.Int64
+}
+
+var errDown = errors.New("store is down")
+
+func (f *flakyStore) PingContext(ctx context.Context) error {
+	if f.down.Load() {
+		return errDown
+	}
+
+	return nil
+}
+
+func (f *flakyStore) SaveURL(ctx context.Context, row json.DBRow) error {
+	f.calls.Add(1)
+
+	if f.down.Load() {
+		return errDown
+	}
+
+	return f.Store.SaveURL(ctx, row)
+}
+
+func (f *flakyStore) UpdateURL(ctx context.Context, row json.DBRow) error {
+	if f.down.Load() {
+		return errDown
+	}
+
+	return f.Store.UpdateURL(ctx, row)
+}
+
+func start(t *testing.T, s *Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func flush(t *testing.T, s *Store) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.NoError(t, s.Flush(ctx))
+}
+
+var fast = Options{RetryInterval: 5 * time.Millisecond, CheckInterval: 5 * time.Millisecond}
+
+func TestStore(t *testing.T) {
+	// владелец берётся из контекста запроса, который отменён к записи в зеркало
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), config.UserIDKey, "alice"))
+	primary, secondary := memory.NewStore(), memory.NewStore()
+	s := NewStore(primary, secondary, fast)
+	start(t, s)
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "a", OriginalURL: "https://mirror.ru/a"}))
+	require.NoError(t, s.IncrementClicks(ctx, "a"))
+	require.NoError(t, s.UpdateURL(ctx, json.DBRow{ShortURL: "a", OriginalURL: "https://mirror.ru/b", UserID: "alice", Tags: []string{"t"}}))
+	cancel()
+	flush(t, s)
+
+	row, err := secondary.GetURL(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", row.UserID)
+	assert.Equal(t, "https://mirror.ru/b", row.OriginalURL)
+	assert.Equal(t, int64(1), row.Clicks)
+	assert.Equal(t, []string{"t"}, row.Tags)
+
+	// отказ основного хранилища в зеркало не попадает
+	err = s.SaveURL(context.Background(), json.DBRow{ShortURL: "c", OriginalURL: "https://mirror.ru/b", UserID: "alice"})
+	assert.ErrorIs(t, err, store.ErrUnique)
+	flush(t, s)
+
+	_, err = secondary.GetURL(context.Background(), "c")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func TestStoreReplay(t *testing.T) {
+	ctx := context.Background()
+	secondary := &flakyStore{Store: memory.NewStore()}
+	secondary.down.Store(true)
+
+	s := NewStore(memory.NewStore(), secondary, fast)
+	start(t, s)
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "a", OriginalURL: "https://replay.ru/a", UserID: "alice"}))
+	require.NoError(t, s.UpdateURL(ctx, json.DBRow{ShortURL: "a", OriginalURL: "https://replay.ru/b", UserID: "alice"}))
+
+	// пока зеркало недоступно, изменения ждут в очереди и повторяются
+	require.Eventually(t, func() bool { return secondary.calls.Load() > 2 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, 2, s.Pending())
+
+	secondary.down.Store(false)
+	flush(t, s)
+
+	// изменения записаны по порядку: сначала ссылка, затем её новая версия
+	row, err := secondary.GetURL(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://replay.ru/b", row.OriginalURL)
+}
+
+func TestStoreSkipsPermanentErrors(t *testing.T) {
+	ctx := context.Background()
+	secondary := memory.NewStore()
+	require.NoError(t, secondary.SaveURL(ctx, json.DBRow{ShortURL: "old", OriginalURL: "https://skip.ru/a", UserID: "alice"}))
+
+	s := NewStore(memory.NewStore(), secondary, fast)
+	start(t, s)
+
+	// в зеркале ссылка уже есть под другим кодом, а обновляемой ссылки там нет
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "a", OriginalURL: "https://skip.ru/a", UserID: "alice"}))
+	require.NoError(t, s.UpdateURL(ctx, json.DBRow{ShortURL: "a", OriginalURL: "https://skip.ru/b", UserID: "alice"}))
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "next", OriginalURL: "https://skip.ru/next", UserID: "alice"}))
+	flush(t, s)
+
+	_, err := secondary.GetURL(ctx, "next")
+	assert.NoError(t, err)
+}
+
+func TestStoreReplaysBatchRows(t *testing.T) {
+	ctx := context.Background()
+	secondary := memory.NewStore()
+	require.NoError(t, secondary.SaveURL(ctx, json.DBRow{ShortURL: "old", OriginalURL: "https://batch.ru/a", UserID: "alice"}))
+	require.NoError(t, secondary.RestoreURLs(ctx, json.BatchURLSlice{{ID: 20, ShortURL: "r1", OriginalURL: "https://restore.ru/1", UserID: "alice"}}))
+
+	s := NewStore(memory.NewStore(), secondary, fast)
+	start(t, s)
+
+	// одна ссылка пачки уже есть в зеркале, остальные всё равно должны туда попасть
+	require.NoError(t, s.SaveBatchURL(ctx, json.BatchURLSlice{
+		{ShortURL: "a", OriginalURL: "https://batch.ru/a", UserID: "alice"},
+		{ShortURL: "b", OriginalURL: "https://batch.ru/b", UserID: "alice"},
+	}))
+	require.NoError(t, s.RestoreURLs(ctx, json.BatchURLSlice{
+		{ID: 20, ShortURL: "r1", OriginalURL: "https://restore.ru/1", UserID: "alice"},
+		{ID: 30, ShortURL: "r2", OriginalURL: "https://restore.ru/2", UserID: "alice"},
+	}))
+	flush(t, s)
+
+	_, err := secondary.GetURL(ctx, "a")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	_, err = secondary.GetURL(ctx, "b")
+	assert.NoError(t, err)
+
+	row, err := secondary.GetURL(ctx, "r2")
+	require.NoError(t, err)
+	assert.Equal(t, 30, row.ID)
+}
+
+func TestStoreQueueLimit(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(memory.NewStore(), memory.NewStore(), Options{QueueSize: 1})
+
+	// без Run очередь не разбирается
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "a", OriginalURL: "https://limit.ru/a", UserID: "alice"}))
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "b", OriginalURL: "https://limit.ru/b", UserID: "alice"}))
+
+	assert.Equal(t, 1, s.Pending())
+	assert.Equal(t, int64(1), s.Dropped())
+}
+
+func TestStoreReadFallback(t *testing.T) {
+	ctx := context.Background()
+	primary := &flakyStore{Store: memory.NewStore()}
+	secondary := memory.NewStore()
+	require.NoError(t, secondary.SaveURL(ctx, json.DBRow{ShortURL: "mirrored", OriginalURL: "https://fallback.ru", UserID: "alice"}))
+
+	s := NewStore(primary, secondary, fast)
+	start(t, s)
+
+	_, err := s.GetURL(ctx, "mirrored")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	primary.down.Store(true)
+	require.Eventually(t, s.PrimaryDown, 5*time.Second, time.Millisecond)
+
+	row, err := s.GetURL(ctx, "mirrored")
+	require.NoError(t, err)
+	assert.Equal(t, "https://fallback.ru", row.OriginalURL)
+
+	rows, err := s.GetUserURLs(ctx, "alice")
+	require.NoError(t, err)
+	assert.Len(t, rows, 1)
+
+	// запись по-прежнему идёт в основное хранилище
+	assert.ErrorIs(t, s.SaveURL(ctx, json.DBRow{ShortURL: "new", OriginalURL: "https://fallback.ru/new", UserID: "alice"}), errDown)
+
+	primary.down.Store(false)
+	require.Eventually(t, func() bool { return !s.PrimaryDown() }, 5*time.Second, time.Millisecond)
+
+	_, err = s.GetURL(ctx, "mirrored")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+// mirrored читает из зеркала, дождавшись записи в него всех изменений. Изменение, которое
+// Store не повторяет в зеркале, не будет видно при чтении
+type mirrored struct {
+	*Store
+	t *testing.T
+}
+
+func (m mirrored) GetURL(ctx context.Context, short string) (json.DBRow, error) {
+	flush(m.t, m.Store)
+	return m.mirror.GetURL(ctx, short)
+}
+
+func (m mirrored) GetUserURLs(ctx context.Context, userID string) ([]json.DBRow, error) {
+	flush(m.t, m.Store)
+	return m.mirror.GetUserURLs(ctx, userID)
+}
+
+func (m mirrored) ListUserURLs(ctx context.Context, opts store.ListOptions) ([]json.DBRow, error) {
+	flush(m.t, m.Store)
+	return m.mirror.ListUserURLs(ctx, opts)
+}
+
+func (m mirrored) GetURLHistory(ctx context.Context, short string) ([]json.URLHistory, error) {
+	flush(m.t, m.Store)
+	return m.mirror.GetURLHistory(ctx, short)
+}
+
+func (m mirrored) Iterate(ctx context.Context, opts store.IterateOptions, fn func(row json.DBRow) error) error {
+	flush(m.t, m.Store)
+	return m.mirror.Iterate(ctx, opts, fn)
+}
+
+func (m mirrored) Stats(ctx context.Context) (store.Stats, error) {
+	flush(m.t, m.Store)
+	return m.mirror.Stats(ctx)
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s := NewStore(memory.NewStore(), memory.NewStore(), fast)
+		start(t, s)
+
+		return mirrored{Store: s, t: t}
+	})
+}
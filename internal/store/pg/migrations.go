@@ -0,0 +1,157 @@
+package pg
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	logger "github.com/laiker/shortener/internal"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+)
+
+// migration изменение схемы в транзакции tx
+type migration func(ctx context.Context, tx pgx.Tx) error
+
+// sqlMigration возвращает миграцию, которая выполняет query
+func sqlMigration(query string) migration {
+	return func(ctx context.Context, tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, query)
+		return err
+	}
+}
+
+// migrations изменения схемы БД. Версия миграции равна её номеру в списке, начиная с единицы.
+// Уже применённые миграции не изменяются, новые добавляются в конец.
+var migrations = []migration{
+	sqlMigration(`CREATE TABLE IF NOT EXISTS urls (
+		id serial PRIMARY KEY,
+		original_url varchar NOT NULL,
+		short_url varchar NOT NULL
+	)`),
+	sqlMigration(`ALTER TABLE urls
+		ADD COLUMN IF NOT EXISTS user_id varchar,
+		ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now(),
+		ADD COLUMN IF NOT EXISTS clicks bigint NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS mode varchar NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS urls_short_url_idx ON urls (short_url);
+	CREATE INDEX IF NOT EXISTS urls_user_id_idx ON urls (user_id)`),
+	sqlMigration(`ALTER TABLE urls ADD COLUMN IF NOT EXISTS redirect_type smallint NOT NULL DEFAULT 0`),
+	sqlMigration(`ALTER TABLE urls ADD COLUMN IF NOT EXISTS passthrough boolean NOT NULL DEFAULT false`),
+	sqlMigration(`ALTER TABLE urls ADD COLUMN IF NOT EXISTS password_hash varchar NOT NULL DEFAULT ''`),
+	sqlMigration(`CREATE TABLE IF NOT EXISTS tags (
+		id serial PRIMARY KEY,
+		user_id varchar NOT NULL,
+		name varchar NOT NULL,
+		UNIQUE (user_id, name)
+	);
+	CREATE TABLE IF NOT EXISTS url_tags (
+		url_id integer NOT NULL REFERENCES urls (id) ON DELETE CASCADE,
+		tag_id integer NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+		PRIMARY KEY (url_id, tag_id)
+	);
+	CREATE INDEX IF NOT EXISTS url_tags_tag_id_idx ON url_tags (tag_id)`),
+	sqlMigration(`ALTER TABLE urls
+		ADD COLUMN IF NOT EXISTS updated_at timestamptz,
+		ADD COLUMN IF NOT EXISTS expires_at timestamptz;
+	CREATE TABLE IF NOT EXISTS url_history (
+		id serial PRIMARY KEY,
+		url_id integer NOT NULL REFERENCES urls (id) ON DELETE CASCADE,
+		original_url varchar NOT NULL,
+		redirect_type smallint NOT NULL DEFAULT 0,
+		expires_at timestamptz,
+		tags varchar[] NOT NULL DEFAULT '{}',
+		changed_at timestamptz NOT NULL DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS url_history_url_id_idx ON url_history (url_id)`),
+	sqlMigration(`CREATE INDEX IF NOT EXISTS urls_user_created_idx ON urls (user_id, created_at, id);
+	CREATE INDEX IF NOT EXISTS urls_user_clicks_idx ON urls (user_id, clicks, id)`),
+	uniqueShortURLs,
+}
+
+// uniqueShortURLs делает коды ссылок уникальными. Повторные коды остались от ссылок, сохранённых
+// под кодом изменённой ссылки. По коду открывалась первая из них, остальные получают код с ID,
+// чтобы их можно было найти. Изменённые коды записываются в лог, чтобы сообщить о них владельцам
+func uniqueShortURLs(ctx context.Context, tx pgx.Tx) error {
+	rows, err := tx.Query(ctx, `UPDATE urls SET short_url = short_url || '-' || id
+		WHERE id NOT IN (SELECT MIN(id) FROM urls GROUP BY short_url)
+		RETURNING id, COALESCE(user_id, ''), short_url`)
+
+	if err != nil {
+		return err
+	}
+
+	var id int
+	var userID, code string
+
+	_, err = pgx.ForEachRow(rows, []any{&id, &userID, &code}, func() error {
+		logger.FromContext(ctx).Info("Short code renamed",
+			zap.Int("id", id),
+			zap.String("user_id", userID),
+			zap.String("old_short_url", strings.TrimSuffix(code, "-"+strconv.Itoa(id))),
+			zap.String("short_url", code),
+		)
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `DROP INDEX IF EXISTS urls_short_url_idx;
+	CREATE UNIQUE INDEX IF NOT EXISTS urls_short_url_key ON urls (short_url)`)
+
+	return err
+}
+
+// migrate применяет в транзакции tx все миграции новее текущей версии схемы
+func migrate(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version integer PRIMARY KEY)`)
+
+	if err != nil {
+		return err
+	}
+
+	// блокируем таблицу, чтобы несколько экземпляров не применяли миграции одновременно
+	_, err = tx.Exec(ctx, `LOCK TABLE schema_migrations IN EXCLUSIVE MODE`)
+
+	if err != nil {
+		return err
+	}
+
+	// миграции ограничены временем ctx, а не statement_timeout пула, рассчитанным на запросы
+	_, err = tx.Exec(ctx, `SET LOCAL statement_timeout = 0`)
+
+	if err != nil {
+		return err
+	}
+
+	var version int
+
+	err = tx.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+
+	if err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if err := migrations[i](ctx, tx); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, i+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SchemaVersion возвращает версию схемы основной БД и число миграций
+func (s *Store) SchemaVersion(ctx context.Context) (version, latest int, err error) {
+	err = s.retryRead(ctx, func() error {
+		return s.conn.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	})
+
+	return version, len(migrations), err
+}
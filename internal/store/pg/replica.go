@@ -0,0 +1,220 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/laiker/shortener/cmd/config"
+	logger "github.com/laiker/shortener/internal"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaOptions настройки чтения с реплики
+type ReplicaOptions struct {
+	// Window сколько после изменения ссылка и ссылки её владельца читаются с основной БД,
+	// чтобы пользователь сразу видел свои изменения, пока реплика отстаёт. Изменения
+	// запоминаются в памяти процесса, поэтому окно действует в пределах одного экземпляра
+	Window time.Duration
+	// CheckInterval период проверки реплики через Ping
+	CheckInterval time.Duration
+}
+
+// replica пул соединений с репликой и состояние чтения с неё
+type replica struct {
+	pool *pgxpool.Pool
+	opts ReplicaOptions
+	// down реплика не ответила на последнюю проверку
+	down atomic.Bool
+	// running Run работает
+	running atomic.Bool
+
+	mu sync.Mutex
+	// recent до какого времени ключ читается с основной БД
+	recent map[string]time.Time
+}
+
+// NewStoreWithReplica возвращает хранилище, которое пишет в conn, а ссылки по коду и ссылки
+// пользователя читает с реплики replicaConn. Реплика проверяется, только пока работает Run.
+func NewStoreWithReplica(conn, replicaConn *pgxpool.Pool, opts ReplicaOptions) *Store {
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = time.Second
+	}
+
+	return &Store{
+		conn:      conn,
+		retryOpts: DefaultRetry,
+		replica: &replica{
+			pool:   replicaConn,
+			opts:   opts,
+			recent: make(map[string]time.Time),
+		},
+	}
+}
+
+func codeKey(short string) string {
+	return "code:" + short
+}
+
+func userKey(userID string) string {
+	return "user:" + userID
+}
+
+// Run проверяет реплику и забывает истёкшие окна чтения с основной БД, пока не отменён ctx
+func (s *Store) Run(ctx context.Context) {
+	if s.replica == nil {
+		return
+	}
+
+	s.replica.running.Store(true)
+	defer s.replica.running.Store(false)
+
+	ticker := time.NewTicker(s.replica.opts.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.replica.check(ctx)
+			s.replica.prune(time.Now())
+		}
+	}
+}
+
+// HasReplica сообщает, что ссылки читаются с реплики
+func (s *Store) HasReplica() bool {
+	return s.replica != nil
+}
+
+// ReplicaRunning сообщает, что Run работает и проверяет реплику
+func (s *Store) ReplicaRunning() bool {
+	return s.replica != nil && s.replica.running.Load()
+}
+
+// ReplicaDown сообщает, что реплика задана и не ответила на последнюю проверку
+func (s *Store) ReplicaDown() bool {
+	return s.replica != nil && s.replica.down.Load()
+}
+
+func (r *replica) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, r.opts.CheckInterval)
+	defer cancel()
+
+	err := r.pool.Ping(ctx)
+
+	if down := err != nil; r.down.Swap(down) != down {
+		if down {
+			logger.Log.Info("Replica is down, reading from primary", zap.Error(err))
+		} else {
+			logger.Log.Info("Replica is up")
+		}
+	}
+}
+
+func (r *replica) prune(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, until := range r.recent {
+		if now.After(until) {
+			delete(r.recent, key)
+		}
+	}
+}
+
+// wrote открывает окно чтения с основной БД для изменённых ключей
+func (s *Store) wrote(keys ...string) {
+	if s.replica == nil || s.replica.opts.Window <= 0 {
+		return
+	}
+
+	until := time.Now().Add(s.replica.opts.Window)
+
+	s.replica.mu.Lock()
+	defer s.replica.mu.Unlock()
+
+	for _, key := range keys {
+		s.replica.recent[key] = until
+	}
+}
+
+// wroteRows открывает окно чтения с основной БД для ссылок rows и их владельцев
+func (s *Store) wroteRows(ctx context.Context, rows ...json.DBRow) {
+	if s.replica == nil {
+		return
+	}
+
+	ctxUserID, _ := ctx.Value(config.UserIDKey).(string)
+	keys := make([]string, 0, 2*len(rows))
+
+	for _, row := range rows {
+		userID := row.UserID
+
+		if userID == "" {
+			userID = ctxUserID
+		}
+
+		keys = append(keys, codeKey(row.ShortURL), userKey(userID))
+	}
+
+	s.wrote(keys...)
+}
+
+// readPool возвращает реплику, если она доступна и ни один из keys недавно не менялся
+func (s *Store) readPool(keys ...string) *pgxpool.Pool {
+	if s.replica == nil || s.replica.down.Load() {
+		return s.conn
+	}
+
+	now := time.Now()
+
+	s.replica.mu.Lock()
+	defer s.replica.mu.Unlock()
+
+	for _, key := range keys {
+		if until, ok := s.replica.recent[key]; ok && now.Before(until) {
+			return s.conn
+		}
+	}
+
+	return s.replica.pool
+}
+
+// read выполняет query на пуле из readPool. Если реплика ответила ошибкой, запрос
+// повторяется на основной БД: отсутствие ссылки ошибкой реплики не считается.
+// На основной БД временные ошибки повторяются, как в остальных методах.
+func (s *Store) read(ctx context.Context, query func(pool *pgxpool.Pool) error, keys ...string) error {
+	return s.readPools(ctx, false, query, keys...)
+}
+
+// readRecheck выполняет query как read, но отсутствие ссылки на реплике перепроверяет на основной БД:
+// окно чтения своих записей есть только у экземпляра, который записал ссылку, а на других
+// экземплярах ссылка не найдётся, пока реплика отстаёт
+func (s *Store) readRecheck(ctx context.Context, query func(pool *pgxpool.Pool) error, keys ...string) error {
+	return s.readPools(ctx, true, query, keys...)
+}
+
+func (s *Store) readPools(ctx context.Context, recheck bool, query func(pool *pgxpool.Pool) error, keys ...string) error {
+	if pool := s.readPool(keys...); pool != s.conn {
+		err := query(pool)
+		missing := errors.Is(err, store.ErrNotFound)
+
+		if err == nil || (missing && !recheck) || ctx.Err() != nil {
+			return err
+		}
+
+		if !missing {
+			logger.FromContext(ctx).Info("Replica read failed, using primary", zap.Error(err))
+		}
+	}
+
+	return s.retryRead(ctx, func() error {
+		return query(s.conn)
+	})
+}
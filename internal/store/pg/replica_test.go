@@ -0,0 +1,87 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/laiker/shortener/cmd/config"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// newPool создаёт пул без подключения: pgxpool соединяется только при первом запросе
+func newPool(t *testing.T) *pgxpool.Pool {
+	pool, err := pgxpool.New(context.Background(), "postgres://localhost:1/replica")
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+func TestReadPool(t *testing.T) {
+	primary, replicaPool := newPool(t), newPool(t)
+	s := NewStoreWithReplica(primary, replicaPool, ReplicaOptions{Window: time.Minute})
+
+	assert.Same(t, replicaPool, s.readPool(codeKey("a"), userKey("alice")))
+
+	// владелец берётся из контекста, если в строке его нет
+	ctx := context.WithValue(context.Background(), config.UserIDKey, "alice")
+	s.wroteRows(ctx, json.DBRow{ShortURL: "a"})
+
+	assert.Same(t, primary, s.readPool(codeKey("a")))
+	assert.Same(t, primary, s.readPool(userKey("alice")))
+	assert.Same(t, replicaPool, s.readPool(codeKey("b"), userKey("bob")))
+
+	// после окна ключи снова читаются с реплики
+	s.replica.prune(time.Now().Add(2 * time.Minute))
+	assert.Same(t, replicaPool, s.readPool(codeKey("a")))
+
+	s.replica.down.Store(true)
+	assert.True(t, s.ReplicaDown())
+	assert.Same(t, primary, s.readPool(codeKey("b")))
+}
+
+func TestReadPoolWithoutReplica(t *testing.T) {
+	primary := newPool(t)
+	s := NewStore(primary)
+	s.wrote(codeKey("a"))
+
+	assert.False(t, s.ReplicaDown())
+	assert.Same(t, primary, s.readPool(codeKey("a")))
+}
+
+func TestReadFallback(t *testing.T) {
+	primary, replicaPool := newPool(t), newPool(t)
+	s := NewStoreWithReplica(primary, replicaPool, ReplicaOptions{})
+
+	var used []*pgxpool.Pool
+	query := func(err error) func(pool *pgxpool.Pool) error {
+		return func(pool *pgxpool.Pool) error {
+			used = append(used, pool)
+
+			if pool == replicaPool {
+				return err
+			}
+
+			return nil
+		}
+	}
+
+	// ошибка реплики повторяется на основной БД
+	require.NoError(t, s.read(context.Background(), query(errors.New("replica is gone"))))
+	assert.Equal(t, []*pgxpool.Pool{replicaPool, primary}, used)
+
+	// отсутствие ссылки на реплике не считается её сбоем
+	used = nil
+	assert.ErrorIs(t, s.read(context.Background(), query(store.ErrNotFound)), store.ErrNotFound)
+	assert.Equal(t, []*pgxpool.Pool{replicaPool}, used)
+
+	// но ссылка, которой нет на отстающей реплике, ищется на основной БД
+	used = nil
+	require.NoError(t, s.readRecheck(context.Background(), query(store.ErrNotFound)))
+	assert.Equal(t, []*pgxpool.Pool{replicaPool, primary}, used)
+}
@@ -0,0 +1,128 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	logger "github.com/laiker/shortener/internal"
+	"go.uber.org/zap"
+	"io"
+	"syscall"
+	"time"
+)
+
+// RetryOptions настройки повтора операций после временных ошибок БД
+type RetryOptions struct {
+	// Retries сколько раз повторить операцию, 0 отключает повтор
+	Retries int
+	// Backoff пауза перед первым повтором, дальше она удваивается
+	Backoff time.Duration
+	// MaxBackoff наибольшая пауза между повторами
+	MaxBackoff time.Duration
+}
+
+// DefaultRetry настройки повтора, с которыми создаётся хранилище
+var DefaultRetry = RetryOptions{Retries: 3, Backoff: 50 * time.Millisecond, MaxBackoff: time.Second}
+
+// errRepeatable помечает обрыв соединения в операции, которую можно выполнить заново:
+// в чтении или в транзакции до коммита, изменения которой отменены
+var errRepeatable = errors.New("operation can be repeated")
+
+// SetRetry задаёт настройки повтора операций
+func (s *Store) SetRetry(opts RetryOptions) {
+	s.retryOpts = opts
+}
+
+// transient сообщает, что операция не выполнена и её можно повторить: запрос не дошёл до
+// сервера, транзакция отменена до коммита, сервер отменил её из-за конфликта сериализации
+// или взаимоблокировки, сервер перезапускается или соединение с ним потеряно.
+// Обрыв соединения посреди изменяющего запроса повтором не считается: запрос мог выполниться.
+func transient(err error) bool {
+	if errors.Is(err, errRepeatable) || pgconn.SafeToRetry(err) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	switch pgErr.Code {
+	case pgerrcode.SerializationFailure, pgerrcode.DeadlockDetected,
+		pgerrcode.AdminShutdown, pgerrcode.CrashShutdown, pgerrcode.CannotConnectNow:
+		return true
+	}
+
+	return pgerrcode.IsConnectionException(pgErr.Code)
+}
+
+// connectionLost сообщает, что соединение с сервером оборвалось
+func connectionLost(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// retry выполняет fn и повторяет её с растущей паузой, пока ошибка временная
+func (s *Store) retry(ctx context.Context, fn func() error) error {
+	backoff := s.retryOpts.Backoff
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+
+		if err == nil || attempt >= s.retryOpts.Retries || !transient(err) || ctx.Err() != nil {
+			return err
+		}
+
+		logger.FromContext(ctx).Info("Database operation failed, retrying", zap.Int("attempt", attempt+1), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; s.retryOpts.MaxBackoff > 0 && backoff > s.retryOpts.MaxBackoff {
+			backoff = s.retryOpts.MaxBackoff
+		}
+	}
+}
+
+// inTx выполняет fn в транзакции и коммитит её. При временной ошибке транзакция
+// выполняется заново, поэтому fn не должна менять ничего, кроме БД.
+func (s *Store) inTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return s.retry(ctx, func() error {
+		tx, err := s.conn.BeginTx(ctx, pgx.TxOptions{})
+
+		if err != nil {
+			return err
+		}
+
+		// в случае неуспешного коммита все изменения транзакции будут отменены
+		defer tx.Rollback(ctx)
+
+		if err := fn(tx); err != nil {
+			return repeatable(err)
+		}
+
+		return tx.Commit(ctx)
+	})
+}
+
+// retryRead выполняет чтение fn с повтором, в том числе после обрыва соединения
+func (s *Store) retryRead(ctx context.Context, fn func() error) error {
+	return s.retry(ctx, func() error {
+		return repeatable(fn())
+	})
+}
+
+// repeatable помечает обрыв соединения как ошибку, после которой операцию можно повторить
+func repeatable(err error) error {
+	if err != nil && connectionLost(err) {
+		return fmt.Errorf("%w: %w", errRepeatable, err)
+	}
+
+	return err
+}
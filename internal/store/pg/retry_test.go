@@ -0,0 +1,88 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"serialization failure", &pgconn.PgError{Code: pgerrcode.SerializationFailure}, true},
+		{"deadlock", fmt.Errorf("save: %w", &pgconn.PgError{Code: pgerrcode.DeadlockDetected}), true},
+		{"connection failure", &pgconn.PgError{Code: pgerrcode.ConnectionFailure}, true},
+		{"admin shutdown", &pgconn.PgError{Code: pgerrcode.AdminShutdown}, true},
+		{"unique violation", &pgconn.PgError{Code: pgerrcode.UniqueViolation}, false},
+		{"statement timeout", &pgconn.PgError{Code: pgerrcode.QueryCanceled}, false},
+		{"connection reset", syscall.ECONNRESET, false},
+		{"repeatable connection reset", repeatable(syscall.ECONNRESET), true},
+		{"repeatable unexpected EOF", repeatable(io.ErrUnexpectedEOF), true},
+		{"other", errors.New("boom"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, transient(test.err))
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	s := &Store{retryOpts: RetryOptions{Retries: 2, Backoff: time.Millisecond}}
+	conflict := &pgconn.PgError{Code: pgerrcode.SerializationFailure}
+
+	calls := 0
+	err := s.retry(ctx, func() error {
+		if calls++; calls < 3 {
+			return conflict
+		}
+
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// после Retries повторов возвращается последняя ошибка
+	calls = 0
+	err = s.retry(ctx, func() error {
+		calls++
+		return conflict
+	})
+	assert.ErrorIs(t, err, conflict)
+	assert.Equal(t, 3, calls)
+
+	// постоянные ошибки не повторяются
+	calls = 0
+	err = s.retry(ctx, func() error {
+		calls++
+		return errors.New("boom")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Store{retryOpts: RetryOptions{Retries: 5, Backoff: time.Hour}}
+
+	calls := 0
+	err := s.retry(ctx, func() error {
+		calls++
+		cancel()
+		return &pgconn.PgError{Code: pgerrcode.SerializationFailure}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
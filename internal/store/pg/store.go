@@ -12,17 +12,24 @@ import (
 	logger "github.com/laiker/shortener/internal"
 	"github.com/laiker/shortener/internal/json"
 	"github.com/laiker/shortener/internal/store"
+	"go.uber.org/zap"
+	"strings"
+	"time"
 )
 
 // Store реализует интерфейс store.Store и позволяет взаимодействовать с СУБД PostgreSQL
 type Store struct {
 	// Поле conn содержит объект соединения с СУБД
 	conn *pgxpool.Pool
+	// replica реплика для чтения ссылок, nil без реплики
+	replica *replica
+	// retryOpts настройки повтора операций после временных ошибок
+	retryOpts RetryOptions
 }
 
 // NewStore возвращает новый экземпляр PostgreSQL хранилища
 func NewStore(conn *pgxpool.Pool) *Store {
-	return &Store{conn: conn}
+	return &Store{conn: conn, retryOpts: DefaultRetry}
 }
 
 func (s *Store) PingContext(ctx context.Context) error {
@@ -31,144 +38,665 @@ func (s *Store) PingContext(ctx context.Context) error {
 
 // Bootstrap подготавливает БД к работе, создавая необходимые таблицы и индексы
 func (s *Store) Bootstrap(ctx context.Context) error {
-	// запускаем транзакцию
-	options := pgx.TxOptions{}
-	tx, err := s.conn.BeginTx(ctx, options)
-	if err != nil {
-		return err
-	}
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		// создаём таблицы и индексы, которых ещё нет
+		err := migrate(ctx, tx)
 
-	// в случае неуспешного коммита все изменения транзакции будут отменены
-	defer tx.Rollback(ctx)
+		logger.FromContext(ctx).Info("BOOTSTRAP")
 
-	// создаём таблицу пользователей и необходимые индексы
-	_, err = tx.Exec(ctx, `
-			CREATE TABLE IF NOT EXISTS urls (
-				id serial PRIMARY KEY,
-				original_url varchar NOT NULL,
-				short_url varchar NOT NULL
-			)
-	    `)
+		return err
+	})
+}
 
-	logger.Log.Info("BOOTSTRAP")
+func (s *Store) SaveURL(ctx context.Context, row json.DBRow) error {
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		return saveURL(ctx, tx, row)
+	})
 
 	if err != nil {
 		return err
 	}
 
-	// коммитим транзакцию
-	return tx.Commit(ctx)
+	s.wroteRows(ctx, row)
+
+	return nil
 }
 
-func (s *Store) SaveURL(ctx context.Context, original string, short string) error {
+// shortURLKey уникальный индекс кодов ссылок
+const shortURLKey = "urls_short_url_key"
 
-	result := s.conn.QueryRow(ctx, "SELECT COUNT(*) as count FROM urls WHERE original_url LIKE $1", original)
+// saveURL сохраняет ссылку в транзакции tx
+func saveURL(ctx context.Context, tx pgx.Tx, row json.DBRow) error {
+	if row.PasswordHash == "" {
+		// уникального индекса на адрес нет, поэтому параллельные сохранения одного адреса
+		// ждут друг друга до конца транзакции, иначе обе проверки ниже не увидят дубля
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", row.OriginalURL); err != nil {
+			return err
+		}
 
-	var countValues int
-	err := result.Scan(&countValues)
+		var code string
 
-	if err != nil {
-		return err
-	}
+		err := tx.QueryRow(ctx, "SELECT short_url FROM urls WHERE original_url = $1 AND password_hash = '' ORDER BY id LIMIT 1", row.OriginalURL).Scan(&code)
 
-	if countValues > 0 {
-		return store.ErrUnique
+		if err == nil {
+			return &store.UniqueError{ShortURL: code}
+		}
+
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
 	}
 
-	userID := ctx.Value(config.UserIDKey)
+	userID := row.UserID
+
+	if userID == "" {
+		userID, _ = ctx.Value(config.UserIDKey).(string)
+	}
 
-	if userID == nil {
+	if userID == "" {
 		return errors.New("Не получен ID пользователя")
 	}
 
-	_, errexec := s.conn.Exec(ctx, "INSERT INTO urls(original_url, short_url, user_id) VALUES($1, $2, $3)", original, short, userID)
+	createdAt := time.Now()
+
+	if row.CreatedAt != nil {
+		createdAt = *row.CreatedAt
+	}
+
+	var id int
+
+	errexec := tx.QueryRow(ctx, "INSERT INTO urls(original_url, short_url, user_id, created_at, mode, redirect_type, passthrough, password_hash, expires_at) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id",
+		row.OriginalURL, row.ShortURL, userID, createdAt, row.Mode, row.RedirectType, row.Passthrough, row.PasswordHash, row.ExpiresAt).Scan(&id)
 
 	if errexec != nil {
 		var pgErr *pgconn.PgError
 		isPgError := errors.As(errexec, &pgErr)
+		if isPgError && pgErr.ConstraintName == shortURLKey {
+			return store.ErrCodeTaken
+		}
 		if isPgError && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
 			return store.ErrUnique
 		}
 		return errexec
 	}
 
-	return nil
+	return setTags(ctx, tx, id, userID, row.Tags)
 }
 
 func (s *Store) SaveBatchURL(ctx context.Context, urls json.BatchURLSlice) error {
-	options := pgx.TxOptions{}
-	tx, err := s.conn.BeginTx(ctx, options)
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		for i := 0; i < len(urls); i++ {
+
+			err := saveURL(ctx, tx, urls[i])
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 
 	if err != nil {
 		return err
 	}
 
-	// в случае неуспешного коммита все изменения транзакции будут отменены
-	defer tx.Rollback(ctx)
+	s.wroteRows(ctx, urls...)
 
-	for i := 0; i < len(urls); i++ {
+	return nil
+}
 
-		err := s.SaveURL(ctx, urls[i].OriginalURL, urls[i].ShortURL)
+// ImportURLs загружает пачку через COPY во временную таблицу и переносит в urls одним
+// запросом только свободные коды. Коды, занятые параллельной вставкой, пропускает
+// уникальный индекс кодов.
+func (s *Store) ImportURLs(ctx context.Context, urls json.BatchURLSlice) ([]string, error) {
+	var skipped []string
 
-		if err != nil {
-			return err
-		}
-	}
+	err := s.inTx(ctx, func(tx pgx.Tx) (err error) {
+		skipped, err = importURLs(ctx, tx, urls)
+		return err
+	})
 
-	return tx.Commit(ctx)
+	return skipped, err
 }
 
-func (s *Store) GetURL(ctx context.Context, short string) (json.DBRow, error) {
+func importURLs(ctx context.Context, tx pgx.Tx, urls json.BatchURLSlice) ([]string, error) {
+	_, err := tx.Exec(ctx, `CREATE TEMP TABLE import_urls (
+		position integer NOT NULL,
+		short_url varchar NOT NULL,
+		original_url varchar NOT NULL,
+		user_id varchar NOT NULL,
+		created_at timestamptz NOT NULL
+	) ON COMMIT DROP`)
 
-	URLRow := json.DBRow{}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"import_urls"},
+		[]string{"position", "short_url", "original_url", "user_id", "created_at"},
+		pgx.CopyFromSlice(len(urls), func(i int) ([]any, error) {
+			createdAt := now
+
+			if urls[i].CreatedAt != nil {
+				createdAt = *urls[i].CreatedAt
+			}
 
-	row, err := s.conn.Query(ctx, "SELECT id, original_url, short_url FROM urls WHERE short_url = $1", short)
+			return []any{i, urls[i].ShortURL, urls[i].OriginalURL, urls[i].UserID, createdAt}, nil
+		}))
 
 	if err != nil {
-		return URLRow, err
+		return nil, err
 	}
 
-	row.Close()
+	rows, err := tx.Query(ctx, `INSERT INTO urls (short_url, original_url, user_id, created_at)
+		SELECT short_url, original_url, user_id, created_at FROM (
+			SELECT DISTINCT ON (short_url) * FROM import_urls ORDER BY short_url, position
+		) i
+		ON CONFLICT (short_url) DO NOTHING
+		RETURNING short_url`)
 
-	for row.Next() {
-		if err := row.Scan(&URLRow.ID, &URLRow.OriginalURL, &URLRow.ShortURL); err != nil {
-			return URLRow, err
+	if err != nil {
+		return nil, err
+	}
+
+	inserted := make(map[string]bool, len(urls))
+
+	for rows.Next() {
+		var short string
+
+		if err := rows.Scan(&short); err != nil {
+			rows.Close()
+			return nil, err
 		}
+
+		inserted[short] = true
 	}
 
-	if err := row.Err(); err != nil {
-		return URLRow, err
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	if URLRow.OriginalURL == "" {
-		return URLRow, errors.New("url not found")
+	var skipped []string
+
+	for _, row := range urls {
+		if inserted[row.ShortURL] {
+			// повтор кода в пачке тоже считается пропущенным
+			delete(inserted, row.ShortURL)
+			continue
+		}
+
+		skipped = append(skipped, row.ShortURL)
 	}
 
-	return URLRow, nil
+	return skipped, nil
 }
 
+// tagsColumn подзапрос, собирающий теги ссылки из urls в массив
+const tagsColumn = "ARRAY(SELECT t.name FROM url_tags ut JOIN tags t ON t.id = ut.tag_id WHERE ut.url_id = urls.id ORDER BY t.name)"
+
+// urlColumns столбцы, которые читаются в json.DBRow функцией scanURL
+const urlColumns = "id, original_url, short_url, COALESCE(user_id, ''), created_at, clicks, mode, redirect_type, passthrough, password_hash, updated_at, expires_at, " + tagsColumn
+
+func scanURL(row pgx.Row) (json.DBRow, error) {
+	URLRow := json.DBRow{}
+	var createdAt time.Time
+
+	err := row.Scan(&URLRow.ID, &URLRow.OriginalURL, &URLRow.ShortURL, &URLRow.UserID, &createdAt, &URLRow.Clicks, &URLRow.Mode, &URLRow.RedirectType, &URLRow.Passthrough, &URLRow.PasswordHash, &URLRow.UpdatedAt, &URLRow.ExpiresAt, &URLRow.Tags)
+	URLRow.CreatedAt = &createdAt
+
+	return URLRow, err
+}
+
+// GetURL читает ссылку с реплики, если она задана, кроме ссылок, изменённых в окне ReplicaOptions.Window.
+// Ссылку, которой нет на реплике, ищет на основной БД
+func (s *Store) GetURL(ctx context.Context, short string) (json.DBRow, error) {
+	var URLRow json.DBRow
+
+	err := s.readRecheck(ctx, func(pool *pgxpool.Pool) (err error) {
+		URLRow, err = getURL(ctx, pool, short)
+		return err
+	}, codeKey(short))
+
+	return URLRow, err
+}
+
+func getURL(ctx context.Context, pool *pgxpool.Pool, short string) (json.DBRow, error) {
+
+	URLRow, err := scanURL(pool.QueryRow(ctx, "SELECT "+urlColumns+" FROM urls WHERE short_url = $1 ORDER BY id LIMIT 1", short))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return URLRow, store.ErrNotFound
+	}
+
+	return URLRow, err
+}
+
+// GetUserURLs читает ссылки пользователя с реплики, если она задана и пользователь
+// не менял ссылки в окне ReplicaOptions.Window
 func (s *Store) GetUserURLs(ctx context.Context, userID string) ([]json.DBRow, error) {
+	var URLs []json.DBRow
+
+	err := s.read(ctx, func(pool *pgxpool.Pool) (err error) {
+		URLs, err = getUserURLs(ctx, pool, userID)
+		return err
+	}, userKey(userID))
+
+	return URLs, err
+}
+
+func getUserURLs(ctx context.Context, pool *pgxpool.Pool, userID string) ([]json.DBRow, error) {
 
 	var URLs []json.DBRow
-	URLRow := json.DBRow{}
 
-	row, err := s.conn.Query(ctx, "SELECT id, original_url, short_url FROM urls WHERE user_id = $1", userID)
+	row, err := pool.Query(ctx, "SELECT "+urlColumns+" FROM urls WHERE user_id = $1", userID)
 
 	if err != nil {
 		return URLs, err
 	}
 
+	defer row.Close()
+
 	for row.Next() {
-		err := row.Scan(&URLRow.ID, &URLRow.OriginalURL, &URLRow.ShortURL)
+		URLRow, err := scanURL(row)
 
 		if err != nil {
 			continue
 		}
-		fmt.Println(URLRow)
+		logger.FromContext(ctx).Debug("user url loaded", zap.String("short_url", URLRow.ShortURL))
+		URLs = append(URLs, URLRow)
+	}
+
+	return URLs, row.Err()
+}
+
+// likeEscaper экранирует спецсимволы шаблона LIKE в строке поиска
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListUserURLs выбирает страницу ключевым условием по (поле сортировки, id) вместо OFFSET,
+// поэтому дальние страницы читаются так же быстро, как первая. Реплика используется так же,
+// как в GetUserURLs
+func (s *Store) ListUserURLs(ctx context.Context, opts store.ListOptions) ([]json.DBRow, error) {
+	var URLs []json.DBRow
+
+	err := s.read(ctx, func(pool *pgxpool.Pool) (err error) {
+		URLs, err = listUserURLs(ctx, pool, opts)
+		return err
+	}, userKey(opts.UserID))
+
+	return URLs, err
+}
+
+func listUserURLs(ctx context.Context, pool *pgxpool.Pool, opts store.ListOptions) ([]json.DBRow, error) {
+	URLs := make([]json.DBRow, 0)
+
+	column := "created_at"
+
+	if opts.Sort == store.SortClicks {
+		column = "clicks"
+	}
+
+	direction, compare := "ASC", ">"
+
+	if opts.Desc {
+		direction, compare = "DESC", "<"
+	}
+
+	args := []any{opts.UserID}
+	query := "SELECT " + urlColumns + " FROM urls WHERE user_id = $1"
+
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if opts.Tag != "" {
+		query += " AND EXISTS (SELECT 1 FROM url_tags ut JOIN tags t ON t.id = ut.tag_id WHERE ut.url_id = urls.id AND t.name = " + arg(opts.Tag) + ")"
+	}
+
+	if opts.Search != "" {
+		query += " AND original_url ILIKE '%' || " + arg(likeEscaper.Replace(opts.Search)) + " || '%'"
+	}
+
+	if opts.After != nil {
+		var after any = opts.After.CreatedAt
+
+		if opts.Sort == store.SortClicks {
+			after = opts.After.Clicks
+		}
+
+		query += fmt.Sprintf(" AND (%s, id) %s (%s, %s)", column, compare, arg(after), arg(opts.After.ID))
+	}
+
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s", column, direction, direction)
+
+	if opts.Limit > 0 {
+		query += " LIMIT " + arg(opts.Limit)
+	}
+
+	rows, err := pool.Query(ctx, query, args...)
+
+	if err != nil {
+		return URLs, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		URLRow, err := scanURL(rows)
+
+		if err != nil {
+			return URLs, err
+		}
+
 		URLs = append(URLs, URLRow)
 	}
 
-	row.Close()
+	return URLs, rows.Err()
+}
+
+// Iterate после временной ошибки продолжает чтение со следующей за переданной в fn ссылки
+func (s *Store) Iterate(ctx context.Context, opts store.IterateOptions, fn func(row json.DBRow) error) error {
+	// ошибку fn не повторяем: она возвращается вызывающему как есть
+	var fnErr error
+
+	err := s.retryRead(ctx, func() error {
+		rows, err := s.conn.Query(ctx, "SELECT "+urlColumns+" FROM urls WHERE ($1 = '' OR user_id = $1) AND id > $2 ORDER BY id", opts.UserID, opts.AfterID)
+
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			URLRow, err := scanURL(rows)
+
+			if err != nil {
+				return err
+			}
+
+			if fnErr = fn(URLRow); fnErr != nil {
+				return nil
+			}
+
+			opts.AfterID = URLRow.ID
+		}
+
+		return rows.Err()
+	})
+
+	if fnErr != nil {
+		return fnErr
+	}
+
+	return err
+}
+
+// RestoreURLs сохраняет пачку в одной транзакции и сдвигает последовательность ID за
+// сохранённые, чтобы новые ссылки не получили занятый ID.
+func (s *Store) RestoreURLs(ctx context.Context, urls json.BatchURLSlice) error {
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		return restoreURLs(ctx, tx, urls)
+	})
+}
+
+func restoreURLs(ctx context.Context, tx pgx.Tx, urls json.BatchURLSlice) error {
+	if _, err := tx.Exec(ctx, "LOCK TABLE urls IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return err
+	}
 
-	return URLs, nil
+	for _, row := range urls {
+		var exists bool
+
+		err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM urls WHERE id = $1 OR short_url = $2)", row.ID, row.ShortURL).Scan(&exists)
+
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return fmt.Errorf("%w: id %d, code %s", store.ErrUnique, row.ID, row.ShortURL)
+		}
+
+		createdAt := time.Now()
+
+		if row.CreatedAt != nil {
+			createdAt = *row.CreatedAt
+		}
+
+		_, err = tx.Exec(ctx, `INSERT INTO urls(id, original_url, short_url, user_id, created_at, clicks, mode, redirect_type, passthrough, password_hash, updated_at, expires_at)
+			VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			row.ID, row.OriginalURL, row.ShortURL, row.UserID, createdAt, row.Clicks, row.Mode, row.RedirectType, row.Passthrough, row.PasswordHash, row.UpdatedAt, row.ExpiresAt)
+
+		if err != nil {
+			return err
+		}
+
+		if err := setTags(ctx, tx, row.ID, row.UserID, row.Tags); err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec(ctx, "SELECT setval(pg_get_serial_sequence('urls', 'id'), (SELECT max(id) FROM urls))")
+
+	return err
+}
+
+func (s *Store) Stats(ctx context.Context) (store.Stats, error) {
+	stats := store.Stats{}
+
+	err := s.retryRead(ctx, func() error {
+		return s.conn.QueryRow(ctx, "SELECT count(*), COALESCE(max(id), 0) FROM urls").Scan(&stats.Count, &stats.LastID)
+	})
+
+	return stats, err
+}
+
+func (s *Store) IncrementClicks(ctx context.Context, short string) error {
+	var tag pgconn.CommandTag
+
+	err := s.retry(ctx, func() (err error) {
+		tag, err = s.conn.Exec(ctx, "UPDATE urls SET clicks = clicks + 1 WHERE short_url = $1", short)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *Store) UpdateURL(ctx context.Context, row json.DBRow) error {
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		return updateURL(ctx, tx, row)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	s.wroteRows(ctx, row)
+
+	return nil
+}
+
+func updateURL(ctx context.Context, tx pgx.Tx, row json.DBRow) error {
+	var id int
+
+	err := tx.QueryRow(ctx, "SELECT id FROM urls WHERE short_url = $1 AND user_id = $2 ORDER BY id LIMIT 1 FOR UPDATE",
+		row.ShortURL, row.UserID).Scan(&id)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return store.ErrNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "INSERT INTO url_history (url_id, original_url, redirect_type, expires_at, tags) "+
+		"SELECT id, original_url, redirect_type, expires_at, "+tagsColumn+" FROM urls WHERE id = $1", id)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE urls SET original_url = $2, redirect_type = $3, expires_at = $4, updated_at = now() WHERE id = $1",
+		id, row.OriginalURL, row.RedirectType, row.ExpiresAt)
+
+	if err != nil {
+		return err
+	}
+
+	return setTags(ctx, tx, id, row.UserID, row.Tags)
+}
+
+// setTags заменяет теги ссылки id, создавая у пользователя недостающие
+func setTags(ctx context.Context, tx pgx.Tx, id int, userID string, tags []string) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM url_tags WHERE url_id = $1", id); err != nil {
+		return err
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, `WITH t AS (
+		INSERT INTO tags (user_id, name) SELECT $2, unnest($3::varchar[])
+		ON CONFLICT (user_id, name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id
+	)
+	INSERT INTO url_tags (url_id, tag_id) SELECT $1, id FROM t`, id, userID, tags)
+
+	return err
+}
+
+func (s *Store) GetURLHistory(ctx context.Context, short string) ([]json.URLHistory, error) {
+	var history []json.URLHistory
+
+	err := s.retryRead(ctx, func() (err error) {
+		history, err = getURLHistory(ctx, s.conn, short)
+		return err
+	})
+
+	return history, err
+}
+
+func getURLHistory(ctx context.Context, pool *pgxpool.Pool, short string) ([]json.URLHistory, error) {
+	history := make([]json.URLHistory, 0)
+
+	rows, err := pool.Query(ctx, "SELECT h.id, h.original_url, h.redirect_type, h.expires_at, h.tags, h.changed_at "+
+		"FROM url_history h JOIN urls u ON u.id = h.url_id WHERE u.short_url = $1 ORDER BY h.id DESC", short)
+
+	if err != nil {
+		return history, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var version json.URLHistory
+		var changedAt time.Time
+
+		err := rows.Scan(&version.Version, &version.OriginalURL, &version.RedirectType, &version.ExpiresAt, &version.Tags, &changedAt)
+
+		if err != nil {
+			return history, err
+		}
+
+		version.ChangedAt = &changedAt
+		history = append(history, version)
+	}
+
+	return history, rows.Err()
+}
+
+func (s *Store) RenameTag(ctx context.Context, userID, oldName, newName string) error {
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		return renameTag(ctx, tx, userID, oldName, newName)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	s.wrote(userKey(userID))
+
+	return nil
+}
+
+func renameTag(ctx context.Context, tx pgx.Tx, userID, oldName, newName string) error {
+	var oldID, newID int
+
+	err := tx.QueryRow(ctx, "SELECT id FROM tags WHERE user_id = $1 AND name = $2 FOR UPDATE", userID, oldName).Scan(&oldID)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return store.ErrNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, "SELECT id FROM tags WHERE user_id = $1 AND name = $2 FOR UPDATE", userID, newName).Scan(&newID)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := tx.Exec(ctx, "UPDATE tags SET name = $2 WHERE id = $1", oldID, newName); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if newID == oldID {
+		return nil
+	}
+
+	// тег с новым именем уже есть: переносим на него ссылки и удаляем старый
+	_, err = tx.Exec(ctx, "INSERT INTO url_tags (url_id, tag_id) SELECT url_id, $2 FROM url_tags WHERE tag_id = $1 ON CONFLICT DO NOTHING", oldID, newID)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM tags WHERE id = $1", oldID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteTag(ctx context.Context, userID, name string) error {
+	var tag pgconn.CommandTag
+
+	err := s.retry(ctx, func() (err error) {
+		tag, err = s.conn.Exec(ctx, "DELETE FROM tags WHERE user_id = $1 AND name = $2", userID, name)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+
+	s.wrote(userKey(userID))
+
+	return nil
 }
@@ -0,0 +1,37 @@
+package pg
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/laiker/shortener/internal/store"
+	"github.com/laiker/shortener/internal/store/storetest"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+// TestStore запускается на БД из TEST_DATABASE_DSN и очищает её таблицы перед каждым тестом,
+// поэтому DSN должен указывать на отдельную тестовую БД
+func TestStore(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	storetest.Run(t, func(t *testing.T) store.Store {
+		ctx := context.Background()
+
+		conn, err := pgxpool.New(ctx, dsn)
+		require.NoError(t, err)
+		t.Cleanup(conn.Close)
+
+		s := NewStore(conn)
+		require.NoError(t, s.Bootstrap(ctx))
+
+		_, err = conn.Exec(ctx, "TRUNCATE urls, tags, url_tags, url_history RESTART IDENTITY CASCADE")
+		require.NoError(t, err)
+
+		return s
+	})
+}
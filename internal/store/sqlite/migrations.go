@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+)
+
+// migrations изменения схемы БД. Схема повторяет таблицы и индексы pg.Store, массивы тегов
+// хранятся строкой JSON, время строкой timeLayout. Версия миграции равна её номеру в списке,
+// начиная с единицы. Уже применённые миграции не изменяются, новые добавляются в конец.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS urls (
+		id integer PRIMARY KEY AUTOINCREMENT,
+		original_url text NOT NULL,
+		short_url text NOT NULL,
+		user_id text,
+		created_at text NOT NULL,
+		clicks integer NOT NULL DEFAULT 0,
+		mode text NOT NULL DEFAULT '',
+		redirect_type integer NOT NULL DEFAULT 0,
+		passthrough boolean NOT NULL DEFAULT false,
+		password_hash text NOT NULL DEFAULT '',
+		updated_at text,
+		expires_at text
+	);
+	CREATE INDEX IF NOT EXISTS urls_short_url_idx ON urls (short_url);
+	CREATE INDEX IF NOT EXISTS urls_user_id_idx ON urls (user_id);
+	CREATE INDEX IF NOT EXISTS urls_user_created_idx ON urls (user_id, created_at, id);
+	CREATE INDEX IF NOT EXISTS urls_user_clicks_idx ON urls (user_id, clicks, id);
+	CREATE TABLE IF NOT EXISTS tags (
+		id integer PRIMARY KEY AUTOINCREMENT,
+		user_id text NOT NULL,
+		name text NOT NULL,
+		UNIQUE (user_id, name)
+	);
+	CREATE TABLE IF NOT EXISTS url_tags (
+		url_id integer NOT NULL REFERENCES urls (id) ON DELETE CASCADE,
+		tag_id integer NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+		PRIMARY KEY (url_id, tag_id)
+	);
+	CREATE INDEX IF NOT EXISTS url_tags_tag_id_idx ON url_tags (tag_id);
+	CREATE TABLE IF NOT EXISTS url_history (
+		id integer PRIMARY KEY AUTOINCREMENT,
+		url_id integer NOT NULL REFERENCES urls (id) ON DELETE CASCADE,
+		original_url text NOT NULL,
+		redirect_type integer NOT NULL DEFAULT 0,
+		expires_at text,
+		tags text NOT NULL DEFAULT '[]',
+		changed_at text NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS url_history_url_id_idx ON url_history (url_id)`,
+	// повторные коды остались от ссылок, сохранённых под кодом изменённой ссылки. По коду
+	// открывалась первая из них, остальные получают код с ID, чтобы их можно было найти
+	`UPDATE urls SET short_url = short_url || '-' || id
+		WHERE id NOT IN (SELECT MIN(id) FROM urls GROUP BY short_url);
+	DROP INDEX IF EXISTS urls_short_url_idx;
+	CREATE UNIQUE INDEX IF NOT EXISTS urls_short_url_key ON urls (short_url)`,
+}
+
+// migrate применяет в транзакции tx все миграции новее текущей версии схемы. Транзакции
+// начинаются с BEGIN IMMEDIATE, поэтому несколько процессов не применят их одновременно.
+func migrate(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version integer PRIMARY KEY)`)
+
+	if err != nil {
+		return err
+	}
+
+	var version int
+
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+
+	if err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, i+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SchemaVersion возвращает версию схемы БД и число миграций
+func (s *Store) SchemaVersion(ctx context.Context) (version, latest int, err error) {
+	err = s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+
+	return version, len(migrations), err
+}
@@ -0,0 +1,634 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	json2 "encoding/json"
+	"errors"
+	"fmt"
+	"github.com/laiker/shortener/cmd/config"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	_ "modernc.org/sqlite"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// timeLayout формат времени в БД. Время хранится в UTC с фиксированной длиной дробной части,
+// поэтому строки сравниваются в том же порядке, что и моменты времени.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// Store реализует интерфейс store.Store поверх встроенной БД SQLite
+type Store struct {
+	db *sql.DB
+}
+
+// Open открывает файл БД SQLite в режиме WAL: чтение не блокирует запись. Транзакции сразу
+// берут блокировку на запись, а занятая БД ожидается, а не возвращает ошибку.
+func Open(path string) (*sql.DB, error) {
+	query := url.Values{}
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", "synchronous(NORMAL)")
+	query.Set("_txlock", "immediate")
+
+	return sql.Open("sqlite", "file:"+path+"?"+query.Encode())
+}
+
+// NewStore возвращает новый экземпляр SQLite хранилища
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func formatNullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+
+	return formatTime(*t)
+}
+
+func parseNullTime(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+
+	t, err := time.Parse(timeLayout, value.String)
+
+	return &t, err
+}
+
+func (s *Store) PingContext(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Bootstrap подготавливает БД к работе, создавая необходимые таблицы и индексы
+func (s *Store) Bootstrap(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := migrate(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) SaveURL(ctx context.Context, row json.DBRow) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := saveURL(ctx, tx, row); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SaveBatchURL сохраняет ссылки в одной транзакции: при ошибке не сохраняется ни одна
+func (s *Store) SaveBatchURL(ctx context.Context, urls json.BatchURLSlice) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, row := range urls {
+		if err := saveURL(ctx, tx, row); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func saveURL(ctx context.Context, tx *sql.Tx, row json.DBRow) error {
+	if row.PasswordHash == "" {
+		var code string
+
+		err := tx.QueryRowContext(ctx, "SELECT short_url FROM urls WHERE original_url = ? AND password_hash = '' ORDER BY id LIMIT 1", row.OriginalURL).Scan(&code)
+
+		if err == nil {
+			return &store.UniqueError{ShortURL: code}
+		}
+
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+
+	var taken bool
+
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM urls WHERE short_url = ?)", row.ShortURL).Scan(&taken); err != nil {
+		return err
+	}
+
+	if taken {
+		return store.ErrCodeTaken
+	}
+
+	if row.UserID == "" {
+		row.UserID, _ = ctx.Value(config.UserIDKey).(string)
+	}
+
+	if row.UserID == "" {
+		return errors.New("Не получен ID пользователя")
+	}
+
+	createdAt := time.Now()
+
+	if row.CreatedAt != nil {
+		createdAt = *row.CreatedAt
+	}
+
+	result, err := tx.ExecContext(ctx, "INSERT INTO urls(original_url, short_url, user_id, created_at, mode, redirect_type, passthrough, password_hash, expires_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		row.OriginalURL, row.ShortURL, row.UserID, formatTime(createdAt), row.Mode, row.RedirectType, row.Passthrough, row.PasswordHash, formatNullTime(row.ExpiresAt))
+
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+
+	if err != nil {
+		return err
+	}
+
+	return setTags(ctx, tx, int(id), row.UserID, row.Tags)
+}
+
+// ImportURLs сохраняет в одной транзакции только ссылки со свободными кодами
+func (s *Store) ImportURLs(ctx context.Context, urls json.BatchURLSlice) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	var skipped []string
+
+	for _, row := range urls {
+		var exists bool
+
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM urls WHERE short_url = ?)", row.ShortURL).Scan(&exists); err != nil {
+			return nil, err
+		}
+
+		// повтор кода в пачке найдётся как уже сохранённый
+		if exists {
+			skipped = append(skipped, row.ShortURL)
+			continue
+		}
+
+		createdAt := time.Now()
+
+		if row.CreatedAt != nil {
+			createdAt = *row.CreatedAt
+		}
+
+		_, err := tx.ExecContext(ctx, "INSERT INTO urls(short_url, original_url, user_id, created_at) VALUES(?, ?, ?, ?)",
+			row.ShortURL, row.OriginalURL, row.UserID, formatTime(createdAt))
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return skipped, tx.Commit()
+}
+
+// tagsColumn подзапрос, собирающий теги ссылки из urls в массив JSON
+const tagsColumn = "(SELECT json_group_array(name) FROM (SELECT t.name FROM url_tags ut JOIN tags t ON t.id = ut.tag_id WHERE ut.url_id = urls.id ORDER BY t.name))"
+
+// urlColumns столбцы, которые читаются в json.DBRow функцией scanURL
+const urlColumns = "id, original_url, short_url, COALESCE(user_id, ''), created_at, clicks, mode, redirect_type, passthrough, password_hash, updated_at, expires_at, " + tagsColumn
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanURL(row scanner) (json.DBRow, error) {
+	URLRow := json.DBRow{}
+	var createdAt, tags string
+	var updatedAt, expiresAt sql.NullString
+
+	err := row.Scan(&URLRow.ID, &URLRow.OriginalURL, &URLRow.ShortURL, &URLRow.UserID, &createdAt, &URLRow.Clicks, &URLRow.Mode, &URLRow.RedirectType, &URLRow.Passthrough, &URLRow.PasswordHash, &updatedAt, &expiresAt, &tags)
+
+	if err != nil {
+		return URLRow, err
+	}
+
+	created, err := time.Parse(timeLayout, createdAt)
+
+	if err != nil {
+		return URLRow, err
+	}
+
+	URLRow.CreatedAt = &created
+
+	if URLRow.UpdatedAt, err = parseNullTime(updatedAt); err != nil {
+		return URLRow, err
+	}
+
+	if URLRow.ExpiresAt, err = parseNullTime(expiresAt); err != nil {
+		return URLRow, err
+	}
+
+	if err := json2.Unmarshal([]byte(tags), &URLRow.Tags); err != nil {
+		return URLRow, err
+	}
+
+	if len(URLRow.Tags) == 0 {
+		URLRow.Tags = nil
+	}
+
+	return URLRow, nil
+}
+
+func (s *Store) GetURL(ctx context.Context, short string) (json.DBRow, error) {
+	URLRow, err := scanURL(s.db.QueryRowContext(ctx, "SELECT "+urlColumns+" FROM urls WHERE short_url = ? ORDER BY id LIMIT 1", short))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return URLRow, store.ErrNotFound
+	}
+
+	return URLRow, err
+}
+
+// queryURLs выполняет запрос к urls и передаёт прочитанные ссылки в fn
+func (s *Store) queryURLs(ctx context.Context, fn func(row json.DBRow) error, query string, args ...any) error {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		URLRow, err := scanURL(rows)
+
+		if err != nil {
+			return err
+		}
+
+		if err := fn(URLRow); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (s *Store) GetUserURLs(ctx context.Context, userID string) ([]json.DBRow, error) {
+	var URLs []json.DBRow
+
+	err := s.queryURLs(ctx, func(row json.DBRow) error {
+		URLs = append(URLs, row)
+		return nil
+	}, "SELECT "+urlColumns+" FROM urls WHERE user_id = ?", userID)
+
+	return URLs, err
+}
+
+// likeEscaper экранирует спецсимволы шаблона LIKE в строке поиска
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListUserURLs выбирает страницу ключевым условием по (поле сортировки, id) вместо OFFSET,
+// поэтому дальние страницы читаются так же быстро, как первая
+func (s *Store) ListUserURLs(ctx context.Context, opts store.ListOptions) ([]json.DBRow, error) {
+	URLs := make([]json.DBRow, 0)
+
+	column := "created_at"
+
+	if opts.Sort == store.SortClicks {
+		column = "clicks"
+	}
+
+	direction, compare := "ASC", ">"
+
+	if opts.Desc {
+		direction, compare = "DESC", "<"
+	}
+
+	args := []any{opts.UserID}
+	query := "SELECT " + urlColumns + " FROM urls WHERE user_id = ?"
+
+	if opts.Tag != "" {
+		query += " AND EXISTS (SELECT 1 FROM url_tags ut JOIN tags t ON t.id = ut.tag_id WHERE ut.url_id = urls.id AND t.name = ?)"
+		args = append(args, opts.Tag)
+	}
+
+	// LIKE в SQLite не учитывает регистр латинских букв
+	if opts.Search != "" {
+		query += ` AND original_url LIKE '%' || ? || '%' ESCAPE '\'`
+		args = append(args, likeEscaper.Replace(opts.Search))
+	}
+
+	if opts.After != nil {
+		var after any = formatTime(opts.After.CreatedAt)
+
+		if opts.Sort == store.SortClicks {
+			after = opts.After.Clicks
+		}
+
+		query += fmt.Sprintf(" AND (%s, id) %s (?, ?)", column, compare)
+		args = append(args, after, opts.After.ID)
+	}
+
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s", column, direction, direction)
+
+	if opts.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, opts.Limit)
+	}
+
+	err := s.queryURLs(ctx, func(row json.DBRow) error {
+		URLs = append(URLs, row)
+		return nil
+	}, query, args...)
+
+	return URLs, err
+}
+
+func (s *Store) Iterate(ctx context.Context, opts store.IterateOptions, fn func(row json.DBRow) error) error {
+	return s.queryURLs(ctx, fn, "SELECT "+urlColumns+" FROM urls WHERE (? = '' OR user_id = ?) AND id > ? ORDER BY id",
+		opts.UserID, opts.UserID, opts.AfterID)
+}
+
+// RestoreURLs сохраняет пачку в одной транзакции. Счётчик AUTOINCREMENT сам сдвигается
+// за сохранённые ID, поэтому новые ссылки не получат занятый ID.
+func (s *Store) RestoreURLs(ctx context.Context, urls json.BatchURLSlice) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, row := range urls {
+		var exists bool
+
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM urls WHERE id = ? OR short_url = ?)", row.ID, row.ShortURL).Scan(&exists)
+
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return fmt.Errorf("%w: id %d, code %s", store.ErrUnique, row.ID, row.ShortURL)
+		}
+
+		createdAt := time.Now()
+
+		if row.CreatedAt != nil {
+			createdAt = *row.CreatedAt
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO urls(id, original_url, short_url, user_id, created_at, clicks, mode, redirect_type, passthrough, password_hash, updated_at, expires_at)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			row.ID, row.OriginalURL, row.ShortURL, row.UserID, formatTime(createdAt), row.Clicks, row.Mode, row.RedirectType, row.Passthrough, row.PasswordHash, formatNullTime(row.UpdatedAt), formatNullTime(row.ExpiresAt))
+
+		if err != nil {
+			return err
+		}
+
+		if err := setTags(ctx, tx, row.ID, row.UserID, row.Tags); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) Stats(ctx context.Context) (store.Stats, error) {
+	stats := store.Stats{}
+
+	err := s.db.QueryRowContext(ctx, "SELECT count(*), COALESCE(max(id), 0) FROM urls").Scan(&stats.Count, &stats.LastID)
+
+	return stats, err
+}
+
+func (s *Store) IncrementClicks(ctx context.Context, short string) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE urls SET clicks = clicks + 1 WHERE short_url = ?", short)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *Store) UpdateURL(ctx context.Context, row json.DBRow) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var id int
+
+	err = tx.QueryRowContext(ctx, "SELECT id FROM urls WHERE short_url = ? AND user_id = ? ORDER BY id LIMIT 1",
+		row.ShortURL, row.UserID).Scan(&id)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	now := formatTime(time.Now())
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO url_history (url_id, original_url, redirect_type, expires_at, tags, changed_at) "+
+		"SELECT id, original_url, redirect_type, expires_at, "+tagsColumn+", ? FROM urls WHERE id = ?", now, id)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE urls SET original_url = ?, redirect_type = ?, expires_at = ?, updated_at = ? WHERE id = ?",
+		row.OriginalURL, row.RedirectType, formatNullTime(row.ExpiresAt), now, id)
+
+	if err != nil {
+		return err
+	}
+
+	if err := setTags(ctx, tx, id, row.UserID, row.Tags); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setTags заменяет теги ссылки id, создавая у пользователя недостающие
+func setTags(ctx context.Context, tx *sql.Tx, id int, userID string, tags []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM url_tags WHERE url_id = ?", id); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		_, err := tx.ExecContext(ctx, "INSERT INTO tags (user_id, name) VALUES (?, ?) ON CONFLICT (user_id, name) DO NOTHING", userID, tag)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO url_tags (url_id, tag_id) SELECT ?, id FROM tags WHERE user_id = ? AND name = ?", id, userID, tag)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) GetURLHistory(ctx context.Context, short string) ([]json.URLHistory, error) {
+	history := make([]json.URLHistory, 0)
+
+	rows, err := s.db.QueryContext(ctx, "SELECT h.id, h.original_url, h.redirect_type, h.expires_at, h.tags, h.changed_at "+
+		"FROM url_history h JOIN urls u ON u.id = h.url_id WHERE u.short_url = ? ORDER BY h.id DESC", short)
+
+	if err != nil {
+		return history, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var version json.URLHistory
+		var expiresAt sql.NullString
+		var tags, changedAt string
+
+		if err := rows.Scan(&version.Version, &version.OriginalURL, &version.RedirectType, &expiresAt, &tags, &changedAt); err != nil {
+			return history, err
+		}
+
+		if version.ExpiresAt, err = parseNullTime(expiresAt); err != nil {
+			return history, err
+		}
+
+		if err := json2.Unmarshal([]byte(tags), &version.Tags); err != nil {
+			return history, err
+		}
+
+		changed, err := time.Parse(timeLayout, changedAt)
+
+		if err != nil {
+			return history, err
+		}
+
+		version.ChangedAt = &changed
+		history = append(history, version)
+	}
+
+	return history, rows.Err()
+}
+
+func (s *Store) RenameTag(ctx context.Context, userID, oldName, newName string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var oldID, newID int
+
+	err = tx.QueryRowContext(ctx, "SELECT id FROM tags WHERE user_id = ? AND name = ?", userID, oldName).Scan(&oldID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, "SELECT id FROM tags WHERE user_id = ? AND name = ?", userID, newName).Scan(&newID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := tx.ExecContext(ctx, "UPDATE tags SET name = ? WHERE id = ?", newName, oldID); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if newID == oldID {
+		return tx.Commit()
+	}
+
+	// тег с новым именем уже есть: переносим на него ссылки и удаляем старый
+	_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO url_tags (url_id, tag_id) SELECT url_id, ? FROM url_tags WHERE tag_id = ?", newID, oldID)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM tags WHERE id = ?", oldID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) DeleteTag(ctx context.Context, userID, name string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM tags WHERE user_id = ? AND name = ?", userID, name)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package sqlite
+
+import (
+	"github.com/laiker/shortener/internal/store"
+	"github.com/laiker/shortener/internal/store/storetest"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		db, err := Open(filepath.Join(t.TempDir(), "urls.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		return NewStore(db)
+	})
+}
+
+func TestOpenWAL(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "urls.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	var mode string
+	require.NoError(t, db.QueryRow("PRAGMA journal_mode").Scan(&mode))
+	require.Equal(t, "wal", mode)
+}
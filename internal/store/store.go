@@ -4,16 +4,121 @@ import (
 	"context"
 	"errors"
 	"github.com/laiker/shortener/internal/json"
+	"io"
 )
 
 var ErrUnique = errors.New("original url is already created")
 
+// ErrNotFound возвращается, если ссылки с таким кодом нет в хранилище
+var ErrNotFound = errors.New("url not found")
+
+// ErrCodeTaken возвращается, если код уже занят другой ссылкой. Так бывает, когда адрес
+// ссылки изменили, а её код остался base64 от прежнего адреса
+var ErrCodeTaken = errors.New("short url is already taken")
+
+// UniqueError ошибка ErrUnique с кодом уже сохранённой ссылки на тот же адрес.
+// Код не всегда равен коду, с которым ссылку пытались сохранить: адрес ссылки могли изменить
+// или сохранить ссылку под псевдонимом
+type UniqueError struct {
+	ShortURL string
+}
+
+func (e *UniqueError) Error() string {
+	return ErrUnique.Error()
+}
+
+func (e *UniqueError) Unwrap() error {
+	return ErrUnique
+}
+
+// IterateOptions выбор ссылок для Store.Iterate
+type IterateOptions struct {
+	// UserID оставляет только ссылки пользователя, пустой выбирает все ссылки
+	UserID string
+	// AfterID начинает обход со следующей за ним ссылки, чтобы продолжить прерванный обход
+	AfterID int
+}
+
+// Stats сводка по ссылкам хранилища
+type Stats struct {
+	Count int
+	// LastID наибольший ID ссылки, 0 для пустого хранилища
+	LastID int
+}
+
 // Store описывает абстрактное хранилище сообщений пользователей
 type Store interface {
-	SaveURL(ctx context.Context, short, original string) error
+	// SaveURL сохраняет ссылку row. Если UserID не заполнен, пользователь берётся из контекста.
+	// Если открытая ссылка на тот же адрес уже есть, возвращает *UniqueError с её кодом, если код занят — ErrCodeTaken.
+	// Ссылки с паролем получают собственный код, поэтому с открытыми ссылками не совпадают
+	SaveURL(ctx context.Context, row json.DBRow) error
 	SaveBatchURL(ctx context.Context, rows json.BatchURLSlice) error
+	// ImportURLs сохраняет ссылки с заданными кодами и возвращает коды, которые пропущены,
+	// потому что уже заняты. Из повторов кода внутри rows сохраняется первый
+	ImportURLs(ctx context.Context, rows json.BatchURLSlice) (skipped []string, err error)
 	PingContext(ctx context.Context) error
 	Bootstrap(ctx context.Context) error
 	GetURL(ctx context.Context, short string) (json.DBRow, error)
 	GetUserURLs(ctx context.Context, userID string) ([]json.DBRow, error)
+	// ListUserURLs возвращает страницу ссылок пользователя opts.UserID
+	ListUserURLs(ctx context.Context, opts ListOptions) ([]json.DBRow, error)
+	// Iterate передаёт в fn ссылки в порядке ID, не загружая их в память все сразу.
+	// Ошибка fn прерывает обход и возвращается из Iterate
+	Iterate(ctx context.Context, opts IterateOptions, fn func(row json.DBRow) error) error
+	// RestoreURLs сохраняет ссылки как есть, с их ID, владельцами и счётчиками переходов.
+	// Если ID или код уже занят, возвращает ErrUnique
+	RestoreURLs(ctx context.Context, rows json.BatchURLSlice) error
+	// Stats возвращает число ссылок и наибольший ID
+	Stats(ctx context.Context) (Stats, error)
+	// IncrementClicks увеличивает счётчик переходов по ссылке
+	IncrementClicks(ctx context.Context, short string) error
+	// UpdateURL заменяет адрес назначения, код ответа, срок действия и теги ссылки row.ShortURL
+	// пользователя row.UserID, сохраняя прежнюю версию в истории. Если такой ссылки нет, возвращает ErrNotFound
+	UpdateURL(ctx context.Context, row json.DBRow) error
+	// GetURLHistory возвращает прежние версии ссылки, начиная с последней
+	GetURLHistory(ctx context.Context, short string) ([]json.URLHistory, error)
+	// RenameTag переименовывает тег пользователя на всех его ссылках. Если тег newName уже есть,
+	// теги объединяются. Если тега oldName нет, возвращает ErrNotFound
+	RenameTag(ctx context.Context, userID, oldName, newName string) error
+	// DeleteTag снимает тег пользователя со всех его ссылок. Если тега нет, возвращает ErrNotFound
+	DeleteTag(ctx context.Context, userID, name string) error
+}
+
+// Backuper реализуют хранилища, которые делают резервную копию без остановки записи
+type Backuper interface {
+	// Backup записывает копию в w и возвращает её размер в байтах
+	Backup(ctx context.Context, w io.Writer) (int64, error)
+}
+
+// Versioned реализуют хранилища, схема которых обновляется миграциями
+type Versioned interface {
+	// SchemaVersion возвращает версию схемы и версию последней миграции, которую знает код
+	SchemaVersion(ctx context.Context) (version, latest int, err error)
+}
+
+// BackuperOf возвращает s или хранилище, которое s оборачивает, если оно реализует Backuper
+func BackuperOf(s Store) (Backuper, bool) {
+	return Find[Backuper](s)
+}
+
+// Find возвращает первое хранилище типа T в цепочке из s и хранилищ, которые оно оборачивает.
+// Обёртки хранилищ возвращают вложенное хранилище методом Unwrap.
+func Find[T any](s Store) (T, bool) {
+	for s != nil {
+		if found, ok := s.(T); ok {
+			return found, true
+		}
+
+		wrapper, ok := s.(interface{ Unwrap() Store })
+
+		if !ok {
+			break
+		}
+
+		s = wrapper.Unwrap()
+	}
+
+	var zero T
+
+	return zero, false
 }
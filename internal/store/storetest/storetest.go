@@ -0,0 +1,597 @@
+// Package storetest проверяет, что реализации store.Store ведут себя одинаково
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/laiker/shortener/cmd/config"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run запускает общие тесты для хранилищ, которые создаёт newStore. Каждый тест получает
+// новое пустое хранилище, Bootstrap для него вызывает Run.
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	tests := map[string]func(t *testing.T, s store.Store){
+		"SaveAndGet":    testSaveAndGet,
+		"SaveBatch":     testSaveBatch,
+		"BatchAtomic":   testBatchAtomic,
+		"Duplicate":     testDuplicate,
+		"ContextUser":   testContextUser,
+		"Concurrency":   testConcurrency,
+		"UserURLs":      testUserURLs,
+		"ListUserURLs":  testListUserURLs,
+		"Clicks":        testClicks,
+		"UpdateURL":     testUpdateURL,
+		"SaveAfterEdit": testSaveAfterEdit,
+		"Tags":          testTags,
+		"Iterate":       testIterate,
+		"ImportURLs":    testImportURLs,
+		"RestoreURLs":   testRestoreURLs,
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			require.NoError(t, s.Bootstrap(context.Background()))
+			test(t, s)
+		})
+	}
+}
+
+// base время создания тестовых ссылок с точностью, которую сохраняют все хранилища
+var base = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func at(minutes int) *time.Time {
+	t := base.Add(time.Duration(minutes) * time.Minute)
+	return &t
+}
+
+func codes(rows []json.DBRow) []string {
+	result := make([]string, len(rows))
+
+	for i, row := range rows {
+		result[i] = row.ShortURL
+	}
+
+	return result
+}
+
+func testSaveAndGet(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	_, err := s.GetURL(ctx, "missing")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{
+		ShortURL:     "code",
+		OriginalURL:  "https://store.ru/",
+		UserID:       "alice",
+		CreatedAt:    at(0),
+		RedirectType: 301,
+		Passthrough:  true,
+		ExpiresAt:    at(60),
+		Tags:         []string{"b", "a"},
+	}))
+
+	row, err := s.GetURL(ctx, "code")
+	require.NoError(t, err)
+	assert.NotZero(t, row.ID)
+	assert.Equal(t, "https://store.ru/", row.OriginalURL)
+	assert.Equal(t, "alice", row.UserID)
+	assert.Equal(t, 301, row.RedirectType)
+	assert.True(t, row.Passthrough)
+	assert.True(t, at(0).Equal(*row.CreatedAt))
+	assert.True(t, at(60).Equal(*row.ExpiresAt))
+	assert.ElementsMatch(t, []string{"a", "b"}, row.Tags)
+	assert.Zero(t, row.Clicks)
+}
+
+func testSaveBatch(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	rows := json.BatchURLSlice{}
+
+	for i := 0; i < 3; i++ {
+		rows = append(rows, json.DBRow{
+			ShortURL:    fmt.Sprintf("batch-%d", i),
+			OriginalURL: fmt.Sprintf("https://batch.ru/%d", i),
+			UserID:      "alice",
+		})
+	}
+
+	require.NoError(t, s.SaveBatchURL(ctx, rows))
+
+	for _, want := range rows {
+		row, err := s.GetURL(ctx, want.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, want.OriginalURL, row.OriginalURL)
+	}
+}
+
+func testBatchAtomic(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "saved", OriginalURL: "https://atomic.ru/saved", UserID: "alice"}))
+
+	// повтор уже сохранённого адреса отменяет всю пачку
+	err := s.SaveBatchURL(ctx, json.BatchURLSlice{
+		{ShortURL: "first", OriginalURL: "https://atomic.ru/first", UserID: "alice"},
+		{ShortURL: "again", OriginalURL: "https://atomic.ru/saved", UserID: "alice"},
+	})
+	assert.ErrorIs(t, err, store.ErrUnique)
+
+	_, err = s.GetURL(ctx, "first")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	// как и повтор адреса внутри пачки
+	err = s.SaveBatchURL(ctx, json.BatchURLSlice{
+		{ShortURL: "one", OriginalURL: "https://atomic.ru/same", UserID: "alice"},
+		{ShortURL: "two", OriginalURL: "https://atomic.ru/same", UserID: "alice"},
+	})
+	assert.ErrorIs(t, err, store.ErrUnique)
+
+	_, err = s.GetURL(ctx, "one")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	stats, err := s.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Count)
+
+	// после отменённых пачек новые ID продолжают расти
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "next", OriginalURL: "https://atomic.ru/next", UserID: "alice"}))
+
+	saved, err := s.GetURL(ctx, "saved")
+	require.NoError(t, err)
+	next, err := s.GetURL(ctx, "next")
+	require.NoError(t, err)
+	assert.Greater(t, next.ID, saved.ID)
+}
+
+func testDuplicate(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "open", OriginalURL: "https://duplicate.ru/", UserID: "alice"}))
+
+	err := s.SaveURL(ctx, json.DBRow{ShortURL: "other", OriginalURL: "https://duplicate.ru/", UserID: "bob"})
+	assert.ErrorIs(t, err, store.ErrUnique)
+
+	// ошибка сообщает код сохранённой ссылки
+	var unique *store.UniqueError
+	require.ErrorAs(t, err, &unique)
+	assert.Equal(t, "open", unique.ShortURL)
+
+	_, err = s.GetURL(ctx, "other")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	// ссылка с паролем на тот же адрес получает свой код
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "locked", OriginalURL: "https://duplicate.ru/", UserID: "bob", PasswordHash: "hash"}))
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "locked2", OriginalURL: "https://duplicate.ru/", UserID: "bob", PasswordHash: "hash"}))
+
+	row, err := s.GetURL(ctx, "open")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", row.UserID)
+
+	row, err = s.GetURL(ctx, "locked")
+	require.NoError(t, err)
+	assert.Equal(t, "hash", row.PasswordHash)
+}
+
+func testContextUser(t *testing.T, s store.Store) {
+	ctx := context.WithValue(context.Background(), config.UserIDKey, "carol")
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "ctx1", OriginalURL: "https://context.ru/1"}))
+	require.NoError(t, s.SaveBatchURL(ctx, json.BatchURLSlice{{ShortURL: "ctx2", OriginalURL: "https://context.ru/2"}}))
+
+	// владелец из ссылки важнее владельца из контекста
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "ctx3", OriginalURL: "https://context.ru/3", UserID: "dave"}))
+
+	rows, err := s.GetUserURLs(ctx, "carol")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"ctx1", "ctx2"}, codes(rows))
+
+	row, err := s.GetURL(ctx, "ctx3")
+	require.NoError(t, err)
+	assert.Equal(t, "dave", row.UserID)
+}
+
+func testConcurrency(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	workers := 16
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "hot", OriginalURL: "https://concurrency.ru/hot", UserID: "alice"}))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3*workers)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			errs <- s.SaveURL(ctx, json.DBRow{
+				ShortURL:    fmt.Sprintf("c%d", i),
+				OriginalURL: fmt.Sprintf("https://concurrency.ru/%d", i),
+				UserID:      fmt.Sprintf("user-%d", i%2),
+			})
+			errs <- s.IncrementClicks(ctx, "hot")
+
+			_, err := s.GetURL(ctx, "hot")
+			errs <- err
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	ids := make(map[int]bool)
+	var hot json.DBRow
+
+	// счётчик берём из Iterate: GetURL кэша отдаёт число переходов на момент кэширования
+	require.NoError(t, s.Iterate(ctx, store.IterateOptions{}, func(row json.DBRow) error {
+		assert.False(t, ids[row.ID], "duplicate id %d", row.ID)
+		ids[row.ID] = true
+
+		if row.ShortURL == "hot" {
+			hot = row
+		}
+
+		return nil
+	}))
+	assert.Len(t, ids, workers+1)
+	assert.Equal(t, int64(workers), hot.Clicks)
+
+	// из параллельных сохранений одного адреса проходит только первое
+	results := make(chan error, workers)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			results <- s.SaveURL(ctx, json.DBRow{
+				ShortURL:    fmt.Sprintf("race-%d", i),
+				OriginalURL: "https://concurrency.ru/race",
+				UserID:      "alice",
+			})
+		}(i)
+	}
+
+	wg.Wait()
+	close(results)
+
+	saved := 0
+
+	for err := range results {
+		if err == nil {
+			saved++
+			continue
+		}
+
+		assert.ErrorIs(t, err, store.ErrUnique)
+	}
+
+	assert.Equal(t, 1, saved)
+}
+
+func testUserURLs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "a1", OriginalURL: "https://user.ru/a1", UserID: "alice"}))
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "a2", OriginalURL: "https://user.ru/a2", UserID: "alice"}))
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "b1", OriginalURL: "https://user.ru/b1", UserID: "bob"}))
+
+	rows, err := s.GetUserURLs(ctx, "alice")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a1", "a2"}, codes(rows))
+
+	rows, err = s.GetUserURLs(ctx, "carol")
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+}
+
+func testListUserURLs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		row := json.DBRow{
+			ShortURL:    fmt.Sprintf("list-%d", i),
+			OriginalURL: fmt.Sprintf("https://List.ru/%d", i),
+			UserID:      "alice",
+			CreatedAt:   at(i),
+		}
+
+		if i%2 == 0 {
+			row.Tags = []string{"even"}
+		}
+
+		require.NoError(t, s.SaveURL(ctx, row))
+	}
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "other", OriginalURL: "https://list.ru/other", UserID: "bob"}))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.IncrementClicks(ctx, "list-1"))
+	}
+
+	require.NoError(t, s.IncrementClicks(ctx, "list-3"))
+
+	opts := store.ListOptions{UserID: "alice", Limit: 2}
+
+	var pages [][]string
+
+	for {
+		rows, err := s.ListUserURLs(ctx, opts)
+		require.NoError(t, err)
+
+		if len(rows) == 0 {
+			break
+		}
+
+		pages = append(pages, codes(rows))
+		cursor := store.CursorOf(rows[len(rows)-1])
+		opts.After = &cursor
+	}
+
+	assert.Equal(t, [][]string{{"list-0", "list-1"}, {"list-2", "list-3"}, {"list-4"}}, pages)
+
+	rows, err := s.ListUserURLs(ctx, store.ListOptions{UserID: "alice", Sort: store.SortClicks, Desc: true, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list-1", "list-3"}, codes(rows))
+
+	rows, err = s.ListUserURLs(ctx, store.ListOptions{UserID: "alice", Tag: "even", Desc: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list-4", "list-2", "list-0"}, codes(rows))
+
+	rows, err = s.ListUserURLs(ctx, store.ListOptions{UserID: "alice", Search: "list.ru/3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list-3"}, codes(rows), "search ignores case")
+
+	rows, err = s.ListUserURLs(ctx, store.ListOptions{UserID: "alice", Search: "%"})
+	require.NoError(t, err)
+	assert.Empty(t, rows, "search has no wildcards")
+}
+
+func testClicks(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "clicks", OriginalURL: "https://clicks.ru/", UserID: "alice"}))
+	require.NoError(t, s.IncrementClicks(ctx, "clicks"))
+	require.NoError(t, s.IncrementClicks(ctx, "clicks"))
+
+	row, err := s.GetURL(ctx, "clicks")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), row.Clicks)
+
+	assert.ErrorIs(t, s.IncrementClicks(ctx, "missing"), store.ErrNotFound)
+}
+
+func testUpdateURL(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{
+		ShortURL:    "edit",
+		OriginalURL: "https://edit.ru/first",
+		UserID:      "alice",
+		Tags:        []string{"old"},
+	}))
+
+	row, err := s.GetURL(ctx, "edit")
+	require.NoError(t, err)
+
+	row.UserID = "bob"
+	row.OriginalURL = "https://edit.ru/foreign"
+	assert.ErrorIs(t, s.UpdateURL(ctx, row), store.ErrNotFound, "only the owner can update a link")
+
+	row.UserID = "alice"
+	row.OriginalURL = "https://edit.ru/second"
+	row.RedirectType = 302
+	row.ExpiresAt = at(10)
+	row.Tags = []string{"new"}
+	require.NoError(t, s.UpdateURL(ctx, row))
+
+	updated, err := s.GetURL(ctx, "edit")
+	require.NoError(t, err)
+	assert.Equal(t, row.ID, updated.ID)
+	assert.Equal(t, "https://edit.ru/second", updated.OriginalURL)
+	assert.Equal(t, 302, updated.RedirectType)
+	assert.True(t, at(10).Equal(*updated.ExpiresAt))
+	assert.Equal(t, []string{"new"}, updated.Tags)
+	assert.NotNil(t, updated.UpdatedAt)
+
+	history, err := s.GetURLHistory(ctx, "edit")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "https://edit.ru/first", history[0].OriginalURL)
+	assert.Equal(t, []string{"old"}, history[0].Tags)
+	assert.NotNil(t, history[0].ChangedAt)
+
+	row.UserID = "alice"
+	row.ShortURL = "missing"
+	assert.ErrorIs(t, s.UpdateURL(ctx, row), store.ErrNotFound)
+}
+
+func testSaveAfterEdit(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "code", OriginalURL: "https://after.ru/first", UserID: "alice"}))
+
+	row, err := s.GetURL(ctx, "code")
+	require.NoError(t, err)
+
+	row.OriginalURL = "https://after.ru/second"
+	require.NoError(t, s.UpdateURL(ctx, row))
+
+	// прежний адрес свободен, но его код остался у изменённой ссылки
+	err = s.SaveURL(ctx, json.DBRow{ShortURL: "code", OriginalURL: "https://after.ru/first", UserID: "bob"})
+	assert.ErrorIs(t, err, store.ErrCodeTaken)
+
+	err = s.SaveBatchURL(ctx, json.BatchURLSlice{
+		{ShortURL: "fresh", OriginalURL: "https://after.ru/fresh", UserID: "bob"},
+		{ShortURL: "code", OriginalURL: "https://after.ru/first", UserID: "bob"},
+	})
+	assert.ErrorIs(t, err, store.ErrCodeTaken)
+
+	_, err = s.GetURL(ctx, "fresh")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	edited, err := s.GetURL(ctx, "code")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", edited.UserID)
+	assert.Equal(t, "https://after.ru/second", edited.OriginalURL)
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "other", OriginalURL: "https://after.ru/first", UserID: "bob"}))
+}
+
+func testTags(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "t1", OriginalURL: "https://tags.ru/1", UserID: "alice", Tags: []string{"promo", "q4"}}))
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "t2", OriginalURL: "https://tags.ru/2", UserID: "alice", Tags: []string{"promo"}}))
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "t3", OriginalURL: "https://tags.ru/3", UserID: "bob", Tags: []string{"promo"}}))
+
+	tagsOf := func(code string) []string {
+		row, err := s.GetURL(ctx, code)
+		require.NoError(t, err)
+		return row.Tags
+	}
+
+	require.NoError(t, s.RenameTag(ctx, "alice", "promo", "sale"))
+	assert.ElementsMatch(t, []string{"sale", "q4"}, tagsOf("t1"))
+	assert.Equal(t, []string{"sale"}, tagsOf("t2"))
+	assert.Equal(t, []string{"promo"}, tagsOf("t3"), "tags of other users stay")
+
+	require.NoError(t, s.RenameTag(ctx, "alice", "q4", "sale"), "renaming to an existing tag merges them")
+	assert.Equal(t, []string{"sale"}, tagsOf("t1"))
+
+	require.NoError(t, s.DeleteTag(ctx, "alice", "sale"))
+	assert.Empty(t, tagsOf("t1"))
+	assert.Empty(t, tagsOf("t2"))
+
+	// изменения тегов не попадают в историю ссылки
+	history, err := s.GetURLHistory(ctx, "t1")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	row, err := s.GetURL(ctx, "t1")
+	require.NoError(t, err)
+	row.OriginalURL = "https://tags.ru/edited"
+	require.NoError(t, s.UpdateURL(ctx, row))
+
+	history, err = s.GetURLHistory(ctx, "t1")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "https://tags.ru/1", history[0].OriginalURL)
+
+	assert.ErrorIs(t, s.RenameTag(ctx, "alice", "missing", "other"), store.ErrNotFound)
+	assert.ErrorIs(t, s.DeleteTag(ctx, "alice", "missing"), store.ErrNotFound)
+}
+
+func testIterate(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, s.SaveURL(ctx, json.DBRow{
+			ShortURL:    fmt.Sprintf("it-%d", i),
+			OriginalURL: fmt.Sprintf("https://iterate.ru/%d", i),
+			UserID:      []string{"alice", "bob"}[i%2],
+		}))
+	}
+
+	collect := func(opts store.IterateOptions) []json.DBRow {
+		var rows []json.DBRow
+
+		require.NoError(t, s.Iterate(ctx, opts, func(row json.DBRow) error {
+			rows = append(rows, row)
+			return nil
+		}))
+
+		return rows
+	}
+
+	all := collect(store.IterateOptions{})
+	assert.Equal(t, []string{"it-0", "it-1", "it-2", "it-3"}, codes(all))
+
+	assert.Equal(t, []string{"it-1", "it-3"}, codes(collect(store.IterateOptions{UserID: "bob"})))
+	assert.Equal(t, []string{"it-2", "it-3"}, codes(collect(store.IterateOptions{AfterID: all[1].ID})))
+
+	stop := errors.New("stop")
+	visited := 0
+
+	err := s.Iterate(ctx, store.IterateOptions{}, func(row json.DBRow) error {
+		visited++
+		return stop
+	})
+
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, visited)
+}
+
+func testImportURLs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "taken", OriginalURL: "https://import.ru/taken", UserID: "alice"}))
+
+	skipped, err := s.ImportURLs(ctx, json.BatchURLSlice{
+		{ShortURL: "new", OriginalURL: "https://import.ru/new", UserID: "alice", CreatedAt: at(0)},
+		{ShortURL: "taken", OriginalURL: "https://import.ru/other", UserID: "alice"},
+		{ShortURL: "new", OriginalURL: "https://import.ru/repeat", UserID: "alice"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"taken", "new"}, skipped)
+
+	row, err := s.GetURL(ctx, "new")
+	require.NoError(t, err)
+	assert.Equal(t, "https://import.ru/new", row.OriginalURL)
+	assert.True(t, at(0).Equal(*row.CreatedAt))
+
+	row, err = s.GetURL(ctx, "taken")
+	require.NoError(t, err)
+	assert.Equal(t, "https://import.ru/taken", row.OriginalURL)
+}
+
+func testRestoreURLs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	stats, err := s.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, store.Stats{}, stats)
+
+	require.NoError(t, s.RestoreURLs(ctx, json.BatchURLSlice{
+		{ID: 10, ShortURL: "r10", OriginalURL: "https://restore.ru/10", UserID: "alice", CreatedAt: at(0), Clicks: 7, Tags: []string{"kept"}},
+		{ID: 20, ShortURL: "r20", OriginalURL: "https://restore.ru/20", UserID: "bob", CreatedAt: at(1)},
+	}))
+
+	row, err := s.GetURL(ctx, "r10")
+	require.NoError(t, err)
+	assert.Equal(t, 10, row.ID)
+	assert.Equal(t, "alice", row.UserID)
+	assert.Equal(t, int64(7), row.Clicks)
+	assert.Equal(t, []string{"kept"}, row.Tags)
+
+	err = s.RestoreURLs(ctx, json.BatchURLSlice{{ID: 20, ShortURL: "r-other", OriginalURL: "https://restore.ru/other", UserID: "bob"}})
+	assert.ErrorIs(t, err, store.ErrUnique)
+
+	stats, err = s.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, store.Stats{Count: 2, LastID: 20}, stats)
+
+	require.NoError(t, s.SaveURL(ctx, json.DBRow{ShortURL: "after", OriginalURL: "https://restore.ru/after", UserID: "alice"}))
+
+	row, err = s.GetURL(ctx, "after")
+	require.NoError(t, err)
+	assert.Greater(t, row.ID, 20, "new links get ids after restored ones")
+}
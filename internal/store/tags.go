@@ -0,0 +1,30 @@
+package store
+
+// ReplaceTag возвращает теги tags, в которых oldName заменён на newName без повторов,
+// а при пустом newName удалён. Второе значение сообщает, был ли oldName среди тегов.
+// Используется хранилищами, которые держат теги списком в самой ссылке.
+func ReplaceTag(tags []string, oldName, newName string) ([]string, bool) {
+	found := false
+	result := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		if tag == oldName {
+			found = true
+			continue
+		}
+
+		if tag != newName {
+			result = append(result, tag)
+		}
+	}
+
+	if !found {
+		return tags, false
+	}
+
+	if newName != "" {
+		result = append(result, newName)
+	}
+
+	return result, true
+}
@@ -0,0 +1,156 @@
+package tracing
+
+import (
+	"context"
+	"github.com/laiker/shortener/internal/json"
+	"github.com/laiker/shortener/internal/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Store оборачивает store.Store и открывает спан на каждый вызов хранилища
+type Store struct {
+	store   store.Store
+	backend string
+}
+
+// NewStore возвращает хранилище s, вызовы которого попадают в трейсы с меткой backend
+func NewStore(s store.Store, backend string) *Store {
+	return &Store{store: s, backend: backend}
+}
+
+// Unwrap возвращает обёрнутое хранилище
+func (s *Store) Unwrap() store.Store {
+	return s.store
+}
+
+func (s *Store) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("store.backend", s.backend))
+	return Start(ctx, "store."+name, trace.WithAttributes(attrs...))
+}
+
+func (s *Store) SaveURL(ctx context.Context, row json.DBRow) (err error) {
+	ctx, span := s.start(ctx, "SaveURL", attribute.String("short_url", row.ShortURL))
+	defer func() { EndSpan(span, err) }()
+
+	return s.store.SaveURL(ctx, row)
+}
+
+func (s *Store) SaveBatchURL(ctx context.Context, rows json.BatchURLSlice) (err error) {
+	ctx, span := s.start(ctx, "SaveBatchURL", attribute.Int("batch_size", len(rows)))
+	defer func() { EndSpan(span, err) }()
+
+	return s.store.SaveBatchURL(ctx, rows)
+}
+
+func (s *Store) ImportURLs(ctx context.Context, rows json.BatchURLSlice) (skipped []string, err error) {
+	ctx, span := s.start(ctx, "ImportURLs", attribute.Int("batch_size", len(rows)))
+	defer func() {
+		span.SetAttributes(attribute.Int("import.skipped", len(skipped)))
+		EndSpan(span, err)
+	}()
+
+	return s.store.ImportURLs(ctx, rows)
+}
+
+func (s *Store) PingContext(ctx context.Context) (err error) {
+	ctx, span := s.start(ctx, "PingContext")
+	defer func() { EndSpan(span, err) }()
+
+	return s.store.PingContext(ctx)
+}
+
+func (s *Store) Bootstrap(ctx context.Context) (err error) {
+	ctx, span := s.start(ctx, "Bootstrap")
+	defer func() { EndSpan(span, err) }()
+
+	return s.store.Bootstrap(ctx)
+}
+
+func (s *Store) GetURL(ctx context.Context, short string) (row json.DBRow, err error) {
+	ctx, span := s.start(ctx, "GetURL", attribute.String("short_url", short))
+	defer func() { EndSpan(span, err) }()
+
+	return s.store.GetURL(ctx, short)
+}
+
+func (s *Store) GetUserURLs(ctx context.Context, userID string) (rows []json.DBRow, err error) {
+	ctx, span := s.start(ctx, "GetUserURLs")
+	defer func() { EndSpan(span, err) }()
+
+	return s.store.GetUserURLs(ctx, userID)
+}
+
+func (s *Store) ListUserURLs(ctx context.Context, opts store.ListOptions) (rows []json.DBRow, err error) {
+	ctx, span := s.start(ctx, "ListUserURLs",
+		attribute.String("list.sort", opts.Sort),
+		attribute.Int("list.limit", opts.Limit),
+		attribute.Bool("list.cursor", opts.After != nil),
+	)
+	defer func() { EndSpan(span, err) }()
+
+	return s.store.ListUserURLs(ctx, opts)
+}
+
+func (s *Store) Iterate(ctx context.Context, opts store.IterateOptions, fn func(row json.DBRow) error) (err error) {
+	ctx, span := s.start(ctx, "Iterate", attribute.Bool("iterate.user", opts.UserID != ""))
+	rows := 0
+	defer func() {
+		span.SetAttributes(attribute.Int("iterate.rows", rows))
+		EndSpan(span, err)
+	}()
+
+	return s.store.Iterate(ctx, opts, func(row json.DBRow) error {
+		rows++
+		return fn(row)
+	})
+}
+
+func (s *Store) RestoreURLs(ctx context.Context, rows json.BatchURLSlice) (err error) {
+	ctx, span := s.start(ctx, "RestoreURLs", attribute.Int("batch_size", len(rows)))
+	defer func() { EndSpan(span, err) }()
+
+	return s.store.RestoreURLs(ctx, rows)
+}
+
+func (s *Store) Stats(ctx context.Context) (stats store.Stats, err error) {
+	ctx, span := s.start(ctx, "Stats")
+	defer func() { EndSpan(span, err) }()
+
+	return s.store.Stats(ctx)
+}
+
+func (s *Store) IncrementClicks(ctx context.Context, short string) (err error) {
+	ctx, span := s.start(ctx, "IncrementClicks", attribute.String("short_url", short))
+	defer func() { EndSpan(span, err) }()
+
+	return s.store.IncrementClicks(ctx, short)
+}
+
+func (s *Store) UpdateURL(ctx context.Context, row json.DBRow) (err error) {
+	ctx, span := s.start(ctx, "UpdateURL", attribute.String("short_url", row.ShortURL))
+	defer func() { EndSpan(span, err) }()
+
+	return s.store.UpdateURL(ctx, row)
+}
+
+func (s *Store) GetURLHistory(ctx context.Context, short string) (history []json.URLHistory, err error) {
+	ctx, span := s.start(ctx, "GetURLHistory", attribute.String("short_url", short))
+	defer func() { EndSpan(span, err) }()
+
+	return s.store.GetURLHistory(ctx, short)
+}
+
+func (s *Store) RenameTag(ctx context.Context, userID, oldName, newName string) (err error) {
+	ctx, span := s.start(ctx, "RenameTag")
+	defer func() { EndSpan(span, err) }()
+
+	return s.store.RenameTag(ctx, userID, oldName, newName)
+}
+
+func (s *Store) DeleteTag(ctx context.Context, userID, name string) (err error) {
+	ctx, span := s.start(ctx, "DeleteTag")
+	defer func() { EndSpan(span, err) }()
+
+	return s.store.DeleteTag(ctx, userID, name)
+}
@@ -0,0 +1,121 @@
+package urlcheck
+
+import (
+	"bufio"
+	"context"
+	logger "github.com/laiker/shortener/internal"
+	"go.uber.org/zap"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Blocklist отклоняет адреса на доменах из файла и на всех их поддоменах.
+// Файл содержит по одному домену в строке, строки с # считаются комментариями.
+type Blocklist struct {
+	path    string
+	mu      sync.RWMutex
+	domains map[string]struct{}
+	modTime time.Time
+}
+
+// NewBlocklist загружает список доменов из файла
+func NewBlocklist(path string) (*Blocklist, error) {
+	b := &Blocklist{path: path}
+
+	if err := b.Reload(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Reload перечитывает файл, если он изменился с прошлой загрузки
+func (b *Blocklist) Reload() error {
+	info, err := os.Stat(b.path)
+
+	if err != nil {
+		return err
+	}
+
+	b.mu.RLock()
+	unchanged := b.domains != nil && info.ModTime().Equal(b.modTime)
+	b.mu.RUnlock()
+
+	if unchanged {
+		return nil
+	}
+
+	file, err := os.Open(b.path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	domains := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		domains[strings.TrimSuffix(strings.ToLower(line), ".")] = struct{}{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.domains = domains
+	b.modTime = info.ModTime()
+	b.mu.Unlock()
+
+	return nil
+}
+
+// Watch перечитывает файл раз в interval, пока не отменён ctx
+func (b *Blocklist) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.Reload(); err != nil {
+				logger.Log.Info("blocklist reload failed", zap.String("path", b.path), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (b *Blocklist) Check(ctx context.Context, u *url.URL) error {
+	host := hostname(u)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	// проверяем сам хост и все родительские домены: evil.com блокирует и a.evil.com
+	for domain := host; domain != ""; {
+		if _, ok := b.domains[domain]; ok {
+			return unsafe("domain %q is blocked", domain)
+		}
+
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package urlcheck
+
+import (
+	"context"
+	logger "github.com/laiker/shortener/internal"
+	"go.uber.org/zap"
+	"net/url"
+)
+
+// Reputation точка расширения для внешних сервисов репутации ссылок
+type Reputation interface {
+	// IsMalicious сообщает, считает ли сервис адрес вредоносным
+	IsMalicious(ctx context.Context, u *url.URL) (bool, error)
+}
+
+// ReputationCheck превращает сервис репутации в проверку.
+// При failOpen ошибка сервиса пропускает адрес, иначе отклоняет его.
+func ReputationCheck(r Reputation, failOpen bool) Checker {
+	return CheckerFunc(func(ctx context.Context, u *url.URL) error {
+		malicious, err := r.IsMalicious(ctx, u)
+
+		if err != nil {
+			logger.FromContext(ctx).Info("reputation check failed", zap.Error(err))
+
+			if failOpen {
+				return nil
+			}
+			return unsafe("reputation check unavailable")
+		}
+
+		if malicious {
+			return unsafe("flagged by reputation service")
+		}
+
+		return nil
+	})
+}
+
+// StaticReputation локальная заглушка сервиса репутации: вредоносными считаются перечисленные хосты
+type StaticReputation map[string]bool
+
+func (s StaticReputation) IsMalicious(ctx context.Context, u *url.URL) (bool, error) {
+	return s[hostname(u)], nil
+}
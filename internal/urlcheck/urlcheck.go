@@ -0,0 +1,237 @@
+package urlcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsafeURL возвращается, если адрес назначения не прошёл проверку
+var ErrUnsafeURL = errors.New("url is not allowed")
+
+// Checker проверяет адрес назначения перед сокращением или редиректом
+type Checker interface {
+	Check(ctx context.Context, u *url.URL) error
+}
+
+// CheckerFunc позволяет использовать обычную функцию как Checker
+type CheckerFunc func(ctx context.Context, u *url.URL) error
+
+func (f CheckerFunc) Check(ctx context.Context, u *url.URL) error {
+	return f(ctx, u)
+}
+
+// Pipeline выполняет проверки по порядку до первой ошибки
+type Pipeline []Checker
+
+func (p Pipeline) Check(ctx context.Context, u *url.URL) error {
+	for _, checker := range p {
+		if err := checker.Check(ctx, u); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func unsafe(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrUnsafeURL, fmt.Sprintf(format, args...))
+}
+
+// hostname возвращает имя хоста в нижнем регистре без завершающей точки
+func hostname(u *url.URL) string {
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+// Schemes пропускает только адреса с перечисленными схемами и непустым хостом
+func Schemes(allowed ...string) Checker {
+	return CheckerFunc(func(ctx context.Context, u *url.URL) error {
+		for _, scheme := range allowed {
+			if strings.EqualFold(u.Scheme, scheme) {
+				if hostname(u) == "" {
+					return unsafe("empty host")
+				}
+				return nil
+			}
+		}
+
+		return unsafe("scheme %q", u.Scheme)
+	})
+}
+
+var localSuffixes = []string{".localhost", ".local", ".internal", ".home.arpa"}
+
+// PrivateNetworks отклоняет адреса локальной сети: loopback, приватные и link-local адреса, localhost.
+// Если resolve выставлен, имя хоста дополнительно разрешается через DNS.
+func PrivateNetworks(resolve bool) Checker {
+	return CheckerFunc(func(ctx context.Context, u *url.URL) error {
+		host := hostname(u)
+
+		if host == "localhost" {
+			return unsafe("local host %q", host)
+		}
+
+		for _, suffix := range localSuffixes {
+			if strings.HasSuffix(host, suffix) {
+				return unsafe("local host %q", host)
+			}
+		}
+
+		ip, err := numericIPv4(host)
+
+		if err != nil {
+			return unsafe("invalid address %q", host)
+		}
+
+		if ip == nil {
+			ip = net.ParseIP(host)
+		}
+
+		if ip != nil {
+			if isPrivate(ip) {
+				return unsafe("private address %q", host)
+			}
+			return nil
+		}
+
+		if !resolve {
+			return nil
+		}
+
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+
+		// несуществующий хост никуда не приведёт, поэтому ошибку разрешения не считаем нарушением
+		if err != nil {
+			return nil
+		}
+
+		for _, addr := range addrs {
+			if isPrivate(addr.IP) {
+				return unsafe("host %q resolves to private address %s", host, addr.IP)
+			}
+		}
+
+		return nil
+	})
+}
+
+// numericIPv4 разбирает хост, который браузер считает IPv4-адресом по правилам WHATWG URL:
+// числа в десятичной, восьмеричной и шестнадцатеричной записи, от одной до четырёх частей,
+// например, 2130706433, 127.1 или 0x7f.0.0.1. Если хост не оканчивается числом, возвращает nil,
+// а если оканчивается, но адрес неверный, — ошибку: браузер такой адрес не откроет.
+func numericIPv4(host string) (net.IP, error) {
+	// IPv6-адрес разбирает net.ParseIP
+	if strings.Contains(host, ":") {
+		return nil, nil
+	}
+
+	parts := strings.Split(host, ".")
+	last := parts[len(parts)-1]
+
+	if _, ok := ipv4Number(last); !ok && strings.Trim(last, "0123456789") != "" {
+		return nil, nil
+	}
+
+	if len(parts) > 4 {
+		return nil, fmt.Errorf("too many parts")
+	}
+
+	var address uint64
+
+	for i, part := range parts {
+		n, ok := ipv4Number(part)
+
+		if !ok {
+			return nil, fmt.Errorf("wrong part %q", part)
+		}
+
+		// последняя часть занимает все оставшиеся байты адреса
+		limit := uint64(255)
+
+		if i == len(parts)-1 {
+			limit = 1<<(8*(5-len(parts))) - 1
+		}
+
+		if n > limit {
+			return nil, fmt.Errorf("part %q is out of range", part)
+		}
+
+		if i < len(parts)-1 {
+			address |= n << (8 * (3 - i))
+		} else {
+			address |= n
+		}
+	}
+
+	return net.IPv4(byte(address>>24), byte(address>>16), byte(address>>8), byte(address)), nil
+}
+
+// ipv4Number разбирает часть IPv4-адреса: 0x — шестнадцатеричная запись, ведущий 0 — восьмеричная
+func ipv4Number(part string) (uint64, bool) {
+	base := 10
+
+	switch {
+	case strings.HasPrefix(part, "0x"):
+		part, base = part[2:], 16
+
+		if part == "" {
+			return 0, true
+		}
+	case len(part) > 1 && part[0] == '0':
+		part, base = part[1:], 8
+	}
+
+	if part == "" || strings.ContainsAny(part, "+-_") {
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(part, base, 64)
+
+	return n, err == nil
+}
+
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isPrivate(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip)
+}
+
+// SelfReference отклоняет ссылки на собственный домен сокращателя, чтобы не получить петлю редиректов
+func SelfReference(baseURL string) Checker {
+	base, err := url.Parse(baseURL)
+	own := ""
+
+	if err == nil {
+		own = ipv4Hostname(base)
+	}
+
+	return CheckerFunc(func(ctx context.Context, u *url.URL) error {
+		if own != "" && ipv4Hostname(u) == own {
+			return unsafe("link to the shortener itself")
+		}
+
+		return nil
+	})
+}
+
+// ipv4Hostname возвращает имя хоста, а для числового IPv4-адреса в любой записи — адрес в обычной записи
+func ipv4Hostname(u *url.URL) string {
+	host := hostname(u)
+
+	if ip, err := numericIPv4(host); err == nil && ip != nil {
+		return ip.String()
+	}
+
+	return host
+}